@@ -0,0 +1,570 @@
+// Package assert checks received telemetry against declarative expectations, eg. for integration tests:
+//
+//	spans:
+//	  - name: charge
+//	    service: checkout
+//	    parent: checkout
+//	    attributes:
+//	      http.status_code: 200
+//	logs:
+//	  - severity: error
+//	    max: 0
+//	metrics:
+//	  - name: orders_total
+//	    value: {gte: 1}
+//
+// Expectations are checked as telemetry arrives, see Spec.Wait. Spans and logs expected at least once, and metrics,
+// are positive: the spec passes once they all hold, and still do after a quiet period without any failing.
+// A spec without positive expectations, like one only expecting no error logs, can only pass once the whole timeout
+// has passed. Exceeding a maximum count fails right away.
+package assert
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	traces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"gopkg.in/yaml.v3"
+
+	"pitr.ca/otelui/query"
+	"pitr.ca/otelui/server"
+	"pitr.ca/otelui/utils"
+)
+
+// Spec are the expectations of a spec file
+type Spec struct {
+	Spans   []SpanExpectation   `yaml:"spans"`
+	Logs    []LogExpectation    `yaml:"logs"`
+	Metrics []MetricExpectation `yaml:"metrics"`
+}
+
+// Count bounds how many records must match, at least one if neither is set
+type Count struct {
+	Count *int `yaml:"count"` // exactly
+	Min   *int `yaml:"min"`
+	Max   *int `yaml:"max"`
+}
+
+// SpanExpectation matches spans by name, service, status, attributes and the name of their parent
+type SpanExpectation struct {
+	Name       string         `yaml:"name"`
+	Service    string         `yaml:"service"`
+	Status     string         `yaml:"status"` // unset, ok or error
+	Parent     string         `yaml:"parent"`
+	Attributes map[string]any `yaml:"attributes"` // of the span or its resource
+	Count      `yaml:",inline"`
+}
+
+// LogExpectation matches logs by severity, service, body and attributes
+type LogExpectation struct {
+	Severity    string         `yaml:"severity"`     // eg. error for ERROR to ERROR4, or ERROR2
+	MinSeverity string         `yaml:"min_severity"` // eg. warn for WARN and above
+	Service     string         `yaml:"service"`
+	Body        string         `yaml:"body"` // substring
+	Attributes  map[string]any `yaml:"attributes"`
+	Count       `yaml:",inline"`
+}
+
+// MetricExpectation is met once a datapoint of a matching series has reached a value
+type MetricExpectation struct {
+	Name       string         `yaml:"name"`
+	Attributes map[string]any `yaml:"attributes"` // of the datapoint, scope or resource
+	Value      Condition      `yaml:"value"`
+}
+
+// Condition compares a value, every set bound must hold
+type Condition struct {
+	Eq  *float64 `yaml:"eq"`
+	Gt  *float64 `yaml:"gt"`
+	Gte *float64 `yaml:"gte"`
+	Lt  *float64 `yaml:"lt"`
+	Lte *float64 `yaml:"lte"`
+}
+
+// Load reads a spec file
+func Load(path string) (*Spec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Spec{}
+	dec := yaml.NewDecoder(strings.NewReader(string(b)))
+	dec.KnownFields(true)
+	if err := dec.Decode(s); err != nil {
+		return nil, fmt.Errorf("invalid spec %s: %w", path, err)
+	}
+	for _, l := range s.Logs {
+		for _, sev := range []string{l.Severity, l.MinSeverity} {
+			if _, _, ok := query.SeverityRange(sev); sev != "" && !ok {
+				return nil, fmt.Errorf("invalid spec %s: unknown severity %q", path, sev)
+			}
+		}
+	}
+	for _, sp := range s.Spans {
+		if _, ok := statusCodes[strings.ToLower(sp.Status)]; !ok {
+			return nil, fmt.Errorf("invalid spec %s: unknown status %q, expected unset, ok or error", path, sp.Status)
+		}
+	}
+	return s, nil
+}
+
+// Result is the outcome of an expectation
+type Result struct {
+	Description string
+	Passed      bool
+	// Final is set when the result can't change as more telemetry arrives, eg. a maximum count was exceeded
+	Final  bool
+	Detail []string // why it failed
+}
+
+// Check evaluates every expectation against the telemetry received so far
+func (s *Spec) Check() []Result {
+	var results []Result
+	if len(s.Spans) > 0 {
+		spans := allSpans()
+		for _, e := range s.Spans {
+			results = append(results, e.check(spans))
+		}
+	}
+	if len(s.Logs) > 0 {
+		ls := server.GetLogs()
+		for _, e := range s.Logs {
+			results = append(results, e.check(ls))
+		}
+	}
+	if len(s.Metrics) > 0 {
+		series := server.GetMetrics()
+		sort.Strings(series)
+		for _, e := range s.Metrics {
+			results = append(results, e.check(series))
+		}
+	}
+	return results
+}
+
+// checkInterval is how often expectations are checked against received telemetry
+const checkInterval = 250 * time.Millisecond
+
+// positive tells whether some expectation waits for telemetry to arrive, instead of only bounding it
+func (s *Spec) positive() bool {
+	if len(s.Metrics) > 0 {
+		return true
+	}
+	for _, e := range s.Spans {
+		if e.Count.min() > 0 {
+			return true
+		}
+	}
+	for _, e := range s.Logs {
+		if e.Count.min() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Wait checks the expectations until they are met, returning the last results and an error if they weren't.
+// They are met once they have held for settle, or at timeout for a spec without positive expectations.
+func (s *Spec) Wait(ctx context.Context, timeout, settle time.Duration) ([]Result, error) {
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	positive := s.positive()
+	var passingSince time.Time
+	for {
+		results := s.Check()
+		passed := passedAll(results)
+		switch {
+		case slices.ContainsFunc(results, func(r Result) bool { return r.Final }):
+			return results, errors.New("expectations can no longer be met")
+		case !passed:
+			passingSince = time.Time{}
+		case passingSince.IsZero():
+			passingSince = time.Now()
+		}
+		if passed && positive && time.Since(passingSince) >= settle {
+			return results, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			if results = s.Check(); passedAll(results) {
+				return results, nil
+			}
+			return results, fmt.Errorf("expectations not met after %s", timeout)
+		case <-ctx.Done():
+			return results, errors.New("interrupted")
+		}
+	}
+}
+
+func passedAll(results []Result) bool {
+	for _, r := range results {
+		if !r.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// Report formats results, with the details of failures
+func Report(results []Result) string {
+	var b strings.Builder
+	for _, r := range results {
+		mark := "✗"
+		if r.Passed {
+			mark = "✓"
+		}
+		fmt.Fprintf(&b, "%s %s\n", mark, r.Description)
+		if !r.Passed {
+			for _, d := range r.Detail {
+				fmt.Fprintf(&b, "    %s\n", d)
+			}
+		}
+	}
+	return b.String()
+}
+
+// spanInTrace is a span with the other spans of its trace, to find its parent
+type spanInTrace struct {
+	*server.Span
+	byID map[string]*traces.Span
+}
+
+func allSpans() []spanInTrace {
+	var res []spanInTrace
+	for _, t := range server.GetTraces() {
+		byID := map[string]*traces.Span{}
+		for _, s := range t.Spans {
+			byID[string(s.Span.SpanId)] = s.Span
+		}
+		for _, s := range t.Spans {
+			res = append(res, spanInTrace{s, byID})
+		}
+	}
+	return res
+}
+
+var statusCodes = map[string]traces.Status_StatusCode{
+	"":      -1,
+	"unset": traces.Status_STATUS_CODE_UNSET,
+	"ok":    traces.Status_STATUS_CODE_OK,
+	"error": traces.Status_STATUS_CODE_ERROR,
+}
+
+func (e SpanExpectation) check(spans []spanInTrace) Result {
+	r := Result{Description: e.describe()}
+	var (
+		matched    int
+		candidates []string // spans with the expected name, and what didn't match
+	)
+	for _, s := range spans {
+		if e.Name != "" && s.Span.Span.Name != e.Name {
+			continue
+		}
+		var diff []string
+		if e.Service != "" {
+			if got := attr(s.Resource.GetAttributes(), "service.name"); got != e.Service {
+				diff = append(diff, fmt.Sprintf("service %q, want %q", got, e.Service))
+			}
+		}
+		if code := statusCodes[strings.ToLower(e.Status)]; code >= 0 && s.Span.Span.Status.GetCode() != code {
+			diff = append(diff, fmt.Sprintf("status %s, want %s", statusName(s.Span.Span.Status.GetCode()), e.Status))
+		}
+		if e.Parent != "" {
+			parent := "(root)"
+			if p, ok := s.byID[string(s.Span.Span.ParentSpanId)]; ok {
+				parent = p.Name
+			} else if len(s.Span.Span.ParentSpanId) > 0 {
+				parent = "(missing " + hex.EncodeToString(s.Span.Span.ParentSpanId) + ")"
+			}
+			if parent != e.Parent {
+				diff = append(diff, fmt.Sprintf("parent %s, want %s", parent, e.Parent))
+			}
+		}
+		diff = append(diff, attributesDiff(e.Attributes, s.Span.Span.Attributes, s.Resource.GetAttributes())...)
+
+		if len(diff) == 0 {
+			matched++
+		} else if c := fmt.Sprintf("%s in trace %s: %s", s.Span.Span.Name, hex.EncodeToString(s.Span.Span.TraceId), strings.Join(diff, ", ")); len(candidates) < 3 && !slices.Contains(candidates, c) {
+			candidates = append(candidates, c)
+		}
+	}
+	e.Count.apply(&r, matched, "spans")
+	if !r.Passed && len(candidates) > 0 {
+		r.Detail = append(r.Detail, "closest spans:")
+		for _, c := range candidates {
+			r.Detail = append(r.Detail, "  "+c)
+		}
+	}
+	return r
+}
+
+func (e SpanExpectation) describe() string {
+	parts := []string{"span"}
+	if e.Name != "" {
+		parts = append(parts, fmt.Sprintf("%q", e.Name))
+	}
+	if e.Service != "" {
+		parts = append(parts, "of "+e.Service)
+	}
+	if e.Parent != "" {
+		parts = append(parts, "under "+e.Parent)
+	}
+	if e.Status != "" {
+		parts = append(parts, "with status "+e.Status)
+	}
+	if len(e.Attributes) > 0 {
+		parts = append(parts, "with "+formatAttributes(e.Attributes))
+	}
+	return strings.Join(parts, " ") + e.Count.describe()
+}
+
+func (e LogExpectation) check(ls []*server.Log) Result {
+	r := Result{Description: e.describe()}
+	lo, hi, _ := query.SeverityRange(e.Severity)
+	atLeast, _, _ := query.SeverityRange(e.MinSeverity)
+	matched := 0
+	for _, l := range ls {
+		// logs that only have a severity text, eg. from stdin, are matched by it
+		sev := query.SeverityOf(l.Log)
+		if (e.Severity != "" && (sev < lo || sev > hi)) ||
+			(e.MinSeverity != "" && sev < atLeast) ||
+			(e.Service != "" && attr(l.ResourceLogs.Resource.GetAttributes(), "service.name") != e.Service) ||
+			!strings.Contains(utils.AnyToString(l.Log.Body), e.Body) ||
+			len(attributesDiff(e.Attributes, l.Log.Attributes, l.ResourceLogs.Resource.GetAttributes())) > 0 {
+			continue
+		}
+		matched++
+		if max := e.Count.max(); max >= 0 && matched > max && len(r.Detail) < 3 {
+			r.Detail = append(r.Detail, fmt.Sprintf("unexpected %s log: %s", strings.TrimPrefix(sev.String(), "SEVERITY_NUMBER_"), utils.AnyToString(l.Log.Body)))
+		}
+	}
+	unexpected := r.Detail
+	r.Detail = nil
+	e.Count.apply(&r, matched, "logs")
+	r.Detail = append(r.Detail, unexpected...)
+	return r
+}
+
+func (e LogExpectation) describe() string {
+	parts := []string{}
+	if e.Severity != "" {
+		parts = append(parts, strings.ToUpper(e.Severity))
+	}
+	if e.MinSeverity != "" {
+		parts = append(parts, strings.ToUpper(e.MinSeverity)+" or above")
+	}
+	parts = append(parts, "logs")
+	if e.Service != "" {
+		parts = append(parts, "of "+e.Service)
+	}
+	if e.Body != "" {
+		parts = append(parts, fmt.Sprintf("containing %q", e.Body))
+	}
+	if len(e.Attributes) > 0 {
+		parts = append(parts, "with "+formatAttributes(e.Attributes))
+	}
+	return strings.Join(parts, " ") + e.Count.describe()
+}
+
+func (e MetricExpectation) check(series []string) Result {
+	r := Result{Description: e.describe()}
+	var seen []string
+	for _, name := range series {
+		metric, _, _ := strings.Cut(name, "{")
+		if metric != e.Name || !seriesHas(name, e.Attributes) {
+			continue
+		}
+		dps := server.GetDatapoints(name)
+		if dps == nil || len(dps.Values) == 0 {
+			continue
+		}
+		for _, v := range dps.Values {
+			if e.Value.holds(v) {
+				r.Passed = true
+				return r
+			}
+		}
+		if len(seen) < 3 {
+			seen = append(seen, fmt.Sprintf("%s last %g", name, dps.Values[len(dps.Values)-1]))
+		}
+	}
+	if len(seen) == 0 {
+		r.Detail = []string{"no matching series received"}
+	} else {
+		r.Detail = append([]string{"matching series:"}, seen...)
+		for i := 1; i < len(r.Detail); i++ {
+			r.Detail[i] = "  " + r.Detail[i]
+		}
+	}
+	return r
+}
+
+func (e MetricExpectation) describe() string {
+	s := "metric " + e.Name
+	if len(e.Attributes) > 0 {
+		s += " with " + formatAttributes(e.Attributes)
+	}
+	return s + " reaching " + e.Value.describe()
+}
+
+// seriesHas tells whether a series, named like key="value" pairs by the server, has all attributes
+func seriesHas(series string, attrs map[string]any) bool {
+	_, labels, _ := strings.Cut(series, "{")
+	for k, v := range attrs {
+		if !strings.Contains(","+labels, fmt.Sprintf(",%s=%q", k, fmt.Sprint(v))) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Condition) holds(v float64) bool {
+	return (c.Eq == nil || v == *c.Eq) &&
+		(c.Gt == nil || v > *c.Gt) &&
+		(c.Gte == nil || v >= *c.Gte) &&
+		(c.Lt == nil || v < *c.Lt) &&
+		(c.Lte == nil || v <= *c.Lte)
+}
+
+func (c Condition) describe() string {
+	var parts []string
+	for _, b := range []struct {
+		op string
+		v  *float64
+	}{{"=", c.Eq}, {">", c.Gt}, {">=", c.Gte}, {"<", c.Lt}, {"<=", c.Lte}} {
+		if b.v != nil {
+			parts = append(parts, fmt.Sprintf("%s %g", b.op, *b.v))
+		}
+	}
+	if len(parts) == 0 {
+		return "any value"
+	}
+	return strings.Join(parts, " and ")
+}
+
+// max is the maximum count, -1 if unbounded
+func (c Count) max() int {
+	switch {
+	case c.Count != nil:
+		return *c.Count
+	case c.Max != nil:
+		return *c.Max
+	}
+	return -1
+}
+
+func (c Count) min() int {
+	switch {
+	case c.Count != nil:
+		return *c.Count
+	case c.Min != nil:
+		return *c.Min
+	case c.Max != nil:
+		return 0
+	}
+	return 1
+}
+
+// apply sets whether matched records are within the count, which is final once the maximum is exceeded
+func (c Count) apply(r *Result, matched int, what string) {
+	min, max := c.min(), c.max()
+	r.Passed = matched >= min && (max < 0 || matched <= max)
+	r.Final = max >= 0 && matched > max
+	if !r.Passed {
+		r.Detail = append(r.Detail, fmt.Sprintf("got %d matching %s, want %s", matched, what, c.want()))
+	}
+}
+
+// describe is the count in the description of an expectation, empty for the default of at least one
+func (c Count) describe() string {
+	if c.min() == 1 && c.max() < 0 {
+		return ""
+	}
+	return " (" + c.want() + ")"
+}
+
+func (c Count) want() string {
+	min, max := c.min(), c.max()
+	switch {
+	case max == 0:
+		return "none"
+	case min == max:
+		return fmt.Sprintf("exactly %d", min)
+	case max < 0:
+		return fmt.Sprintf("at least %d", min)
+	case min == 0:
+		return fmt.Sprintf("at most %d", max)
+	}
+	return fmt.Sprintf("%d to %d", min, max)
+}
+
+// attributesDiff lists the expected attributes missing from all of attrs, or with another value
+func attributesDiff(want map[string]any, attrs ...[]*v1.KeyValue) []string {
+	keys := make([]string, 0, len(want))
+	for k := range want {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var diff []string
+	for _, k := range keys {
+		w := fmt.Sprint(want[k])
+		got, found := "", false
+		for _, kvs := range attrs {
+			for _, kv := range kvs {
+				if kv.Key == k {
+					got, found = valueString(kv.Value), true
+				}
+			}
+		}
+		switch {
+		case !found:
+			diff = append(diff, fmt.Sprintf("%s missing, want %s", k, w))
+		case got != w:
+			diff = append(diff, fmt.Sprintf("%s = %s, want %s", k, got, w))
+		}
+	}
+	return diff
+}
+
+// valueString formats an attribute value like YAML values are formatted, so they can be compared
+func valueString(v *v1.AnyValue) string {
+	if d, ok := v.GetValue().(*v1.AnyValue_DoubleValue); ok {
+		return fmt.Sprint(d.DoubleValue)
+	}
+	return utils.AnyToString(v)
+}
+
+func attr(kvs []*v1.KeyValue, key string) string {
+	for _, kv := range kvs {
+		if kv.Key == key {
+			return utils.AnyToString(kv.Value)
+		}
+	}
+	return ""
+}
+
+func formatAttributes(attrs map[string]any) string {
+	parts := make([]string, 0, len(attrs))
+	for k, v := range attrs {
+		parts = append(parts, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func statusName(c traces.Status_StatusCode) string {
+	return strings.ToLower(strings.TrimPrefix(c.String(), "STATUS_CODE_"))
+}
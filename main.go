@@ -2,36 +2,390 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"log/slog"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"pitr.ca/otelui/assert"
 	"pitr.ca/otelui/server"
 	"pitr.ca/otelui/ui"
 )
 
+const usage = `Usage:
+  otelui [flags]                receive telemetry and show it, and import it with --import
+  otelui record <file> [flags]  same, and record every received request to file
+  otelui replay <file> [flags]  show a recording, or send it to another endpoint with --to
+  otelui assert --spec <file>   receive telemetry until the expectations of file are met, or exit with status 1
+
+Every flag can also be set with an OTELUI_ environment variable, eg. OTELUI_GRPC_ADDR.
+
+Flags:
+`
+
 func main() {
+	cmd, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "":
+		err = runMain(args)
+	case "record":
+		err = runRecord(args)
+	case "replay":
+		err = runReplay(args)
+	case "assert":
+		err = runAssert(args)
+	default:
+		err = fmt.Errorf("unknown command %q, see %s -help", cmd, os.Args[0])
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func runMain(args []string) error {
+	var (
+		cfg        server.Config
+		importPath string
+	)
+	fs := newFlagSet("otelui", &cfg)
+	fs.StringVar(&importPath, "import", "", "import OTLP/JSON export requests from this file, eg. written by the collector's file exporter, - for stdin")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if importPath == "" {
+		return run(cfg, nil)
+	}
+
+	f := os.Stdin
+	if importPath != "-" {
+		var err error
+		if f, err = os.Open(importPath); err != nil {
+			return err
+		}
+		defer f.Close()
+	}
+	return run(cfg, func(ctx context.Context) {
+		n, err := server.Import(f)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to import", "path", importPath, "imported", n, "err", err)
+		} else {
+			slog.InfoContext(ctx, "imported", "path", importPath, "imported", n)
+		}
+	})
+}
+
+func runRecord(args []string) error {
+	var cfg server.Config
+	fs := newFlagSet("record", &cfg)
+	pos, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	cfg.Record = pos[0]
+	return run(cfg, nil)
+}
+
+func runReplay(args []string) error {
+	var (
+		cfg     server.Config
+		speed   = 1.0
+		instant bool
+		to      string
+	)
+	fs := newFlagSet("replay", &cfg)
+	fs.Var((*speedFlag)(&speed), "speed", "replay speed relative to the recording, eg. 2x")
+	fs.BoolVar(&instant, "instant", false, "replay as fast as possible")
+	fs.StringVar(&to, "to", "", "send the recording to this OTLP endpoint instead of showing it, eg. grpc://localhost:4317 or http://localhost:4318")
+	pos, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if instant {
+		speed = 0
+	}
+
+	f, err := os.Open(pos[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if to != "" {
+		sent, err := server.ReplayTo(context.Background(), f, speed, to)
+		fmt.Printf("sent %d requests to %s\n", sent, to)
+		return err
+	}
+
+	return run(cfg, func(ctx context.Context) {
+		if err := server.Replay(ctx, f, speed); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to replay recording", "err", err)
+		}
+	})
+}
+
+func runAssert(args []string) error {
+	var (
+		cfg      server.Config
+		specPath string
+		timeout  time.Duration
+		settle   time.Duration
+	)
+	fs := newFlagSet("assert", &cfg)
+	fs.StringVar(&specPath, "spec", "", "YAML file of expected spans, logs and metrics")
+	fs.DurationVar(&timeout, "timeout", 30*time.Second, "how long to wait for the expectations to be met")
+	fs.DurationVar(&settle, "settle", 2*time.Second, "how long the expectations must keep being met before passing")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if specPath == "" {
+		fs.Usage()
+		return errors.New("assert: --spec is required")
+	}
+	spec, err := assert.Load(specPath)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := server.Start(ctx, cancel, cfg); err != nil {
+		return err
+	}
+
+	results, err := spec.Wait(ctx, timeout, settle)
+	fmt.Print(assert.Report(results))
+	if err != nil {
+		return fmt.Errorf("assert: %w", err)
+	}
+	return nil
+}
+
+// headless runs without the UI, until interrupted
+var headless bool
+
+// defaultHeadlessAPIAddr is where the API listens in headless mode if --api-addr isn't given
+const defaultHeadlessAPIAddr = "127.0.0.1:4319"
+
+// run starts the receivers and the UI, and blocks until the UI exits.
+// feed, if not nil, is run in the background once the receivers are started.
+func run(cfg server.Config, feed func(ctx context.Context)) error {
 	logs := io.Discard
+	level := slog.LevelDebug
+	if headless {
+		// without a UI to draw over, logs go to stderr
+		logs, level = os.Stderr, slog.LevelInfo
+		if cfg.APIAddr == "" {
+			cfg.APIAddr = defaultHeadlessAPIAddr
+		}
+	}
 
 	if os.Getenv("DEBUG") != "" {
 		f, err := os.OpenFile("debug.log", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
 		if err != nil {
-			fmt.Printf("error opening file for logging: %s", err)
-			return
+			return fmt.Errorf("error opening file for logging: %w", err)
 		}
 		logs = f
 	}
 
 	slog.SetDefault(slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
+		Level: level,
 	})))
 	log.Default()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	server.Start(ctx, cancel)
-	go ui.Run(ctx, cancel)
+	defer cancel()
+	if headless {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+	}
+	if err := server.Start(ctx, cancel, cfg); err != nil {
+		return err
+	}
+	if headless {
+		slog.InfoContext(ctx, "receiving without UI", "grpc", cfg.GRPCAddr, "http", cfg.HTTPAddr, "api", cfg.APIAddr)
+	} else {
+		go ui.Run(ctx, cancel)
+	}
+	if feed != nil {
+		go feed(ctx)
+	}
 
 	<-ctx.Done()
+	return nil
+}
+
+// newFlagSet creates a flag set with the flags configuring receivers and storage
+func newFlagSet(name string, cfg *server.Config) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&cfg.GRPCAddr, "grpc-addr", ":4317", "listen address of the OTLP gRPC receiver, empty to disable")
+	fs.StringVar(&cfg.HTTPAddr, "http-addr", ":4318", "listen address of the OTLP HTTP receiver, empty to disable")
+	fs.StringVar(&cfg.TLS.CertFile, "tls-cert", "", "PEM certificate file to serve both receivers over TLS")
+	fs.StringVar(&cfg.TLS.KeyFile, "tls-key", "", "PEM key file of --tls-cert")
+	fs.StringVar(&cfg.TLS.ClientCAFile, "tls-client-ca", "", "PEM CA bundle to require and verify client certificates against (mTLS)")
+	fs.BoolVar(&cfg.TLS.SelfSigned, "tls-self-signed", false, "serve both receivers over TLS with a self-signed certificate generated on startup")
+	cfg.MaxBodySize = 20 * 1024 * 1024
+	fs.Var((*megabytes)(&cfg.MaxBodySize), "max-body-size", "maximum size of an OTLP/HTTP request after decompression in MB, 0 for unlimited")
+	fs.BoolVar(&headless, "headless", false, "run without the UI until interrupted, serving the API on "+defaultHeadlessAPIAddr+" unless --api-addr is given")
+	fs.StringVar(&cfg.APIAddr, "api-addr", "", "listen address of the JSON query API, empty to disable")
+	fs.Var((*scrapeFlag)(&cfg.Scrape), "scrape", "scrape a Prometheus metrics endpoint, eg. http://localhost:9090/metrics@5s, can be repeated")
+	fs.StringVar(&cfg.StatsDAddr, "statsd-addr", "", "UDP listen address of the StatsD/DogStatsD receiver, eg. :8125, empty to disable")
+	fs.DurationVar(&cfg.StatsDFlush, "statsd-flush", 10*time.Second, "how often aggregated StatsD metrics are stored")
+	fs.StringVar(&cfg.SyslogUDP, "syslog-udp", "", "UDP listen address of the syslog receiver, eg. :5514, empty to disable")
+	fs.StringVar(&cfg.SyslogTCP, "syslog-tcp", "", "TCP listen address of the syslog receiver, eg. :5514, empty to disable")
+	fs.Var((*listFlag)(&cfg.Tail), "tail", "follow a file and read its lines as logs, - for stdin, can be repeated")
+	fs.StringVar(&cfg.Forward, "forward", "", "forward every received request to this OTLP endpoint, eg. grpc://localhost:4317 or http://localhost:4318")
+	fs.IntVar(&cfg.ForwardQueue, "forward-queue", 1000, "maximum number of requests waiting to be forwarded, more are dropped")
+	fs.StringVar(&cfg.DataDir, "data-dir", "", "directory to keep received telemetry in across restarts, empty to keep it in memory only")
+	cfg.MaxWALSize = 1024 * 1024 * 1024
+	fs.Var((*megabytes)(&cfg.MaxWALSize), "max-wal-size", "maximum size of the telemetry kept in --data-dir in MB, the oldest is dropped first, 0 for unlimited")
+	fs.IntVar(&cfg.Limits.MaxPayloads, "max-payloads", 0, "maximum number of payloads to keep, 0 for unlimited")
+	fs.IntVar(&cfg.Limits.MaxLogs, "max-logs", 0, "maximum number of logs to keep, 0 for unlimited")
+	fs.IntVar(&cfg.Limits.MaxTraces, "max-traces", 0, "maximum number of traces to keep, 0 for unlimited")
+	fs.IntVar(&cfg.Limits.MaxSpans, "max-spans", 0, "maximum number of spans to keep, 0 for unlimited")
+	fs.IntVar(&cfg.Limits.MaxDatapoints, "max-datapoints", 0, "maximum number of datapoints to keep per metric series, 0 for unlimited")
+	fs.DurationVar(&cfg.Limits.MaxAge, "max-age", 0, "drop telemetry received longer ago than this, 0 to keep forever")
+	fs.Var((*megabytes)(&cfg.Limits.MaxMemory), "max-memory", "approximate memory budget for received telemetry in MB, 0 for unlimited")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args that can have flags before and after the positional
+// arguments, of which there must be exactly n, then applies environment variables
+func parseFlags(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(pos) != n {
+		fs.Usage()
+		return nil, fmt.Errorf("%s: expected %d arguments, got %d", fs.Name(), n, len(pos))
+	}
+	return pos, flagsFromEnv(fs)
+}
+
+// flagsFromEnv sets flags that were not given on the command line from their
+// environment variable, eg. --grpc-addr from OTELUI_GRPC_ADDR.
+// A variable that is set to an empty string is applied as is, so it can disable a receiver.
+func flagsFromEnv(fs *flag.FlagSet) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if given[f.Name] {
+			return
+		}
+		name := "OTELUI_" + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v, ok := os.LookupEnv(name); ok {
+			if e := f.Value.Set(v); e != nil {
+				err = errors.Join(err, fmt.Errorf("invalid value %q for %s: %w", v, name, e))
+			}
+		}
+	})
+	return err
+}
+
+// megabytes is a flag given in MB and stored in bytes
+type megabytes int
+
+func (m *megabytes) String() string { return strconv.Itoa(int(*m) / 1024 / 1024) }
+
+func (m *megabytes) Set(s string) error {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*m = megabytes(v * 1024 * 1024)
+	return nil
+}
+
+// speedFlag is a replay speed multiplier, eg. 2x or 0.5
+type speedFlag float64
+
+func (s *speedFlag) String() string { return strconv.FormatFloat(float64(*s), 'g', -1, 64) + "x" }
+
+func (s *speedFlag) Set(v string) error {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(v, "x"), 64)
+	if err != nil {
+		return err
+	}
+	if f <= 0 {
+		return errors.New("speed must be positive")
+	}
+	*s = speedFlag(f)
+	return nil
+}
+
+// listFlag are values given by repeating the flag
+type listFlag []string
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// scrapeFlag are scrape targets, given by repeating the flag or separated by commas
+type scrapeFlag []server.ScrapeTarget
+
+func (s *scrapeFlag) String() string {
+	if s == nil {
+		return ""
+	}
+	targets := make([]string, len(*s))
+	for i, t := range *s {
+		targets[i] = t.URL + "@" + t.Interval.String()
+	}
+	return strings.Join(targets, ",")
+}
+
+func (s *scrapeFlag) Set(v string) error {
+	for t := range strings.SplitSeq(v, ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		target, err := server.ParseScrapeTarget(t)
+		if err != nil {
+			return err
+		}
+		*s = append(*s, target)
+	}
+	return nil
 }
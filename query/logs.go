@@ -0,0 +1,273 @@
+package query
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+
+	"pitr.ca/otelui/server"
+	"pitr.ca/otelui/utils"
+)
+
+// LogFilter tells whether a log matches a query
+type LogFilter func(*server.Log) bool
+
+// ParseLogs parses a log query of comparisons combined with AND, OR, NOT and parentheses, where AND can be left out:
+//
+//	severity>=WARN                  severity=ERROR matches ERROR to ERROR4
+//	service.name="api"              also service
+//	body=~"timeout.*"               regexps match anywhere, unless anchored with ^ and $
+//	trace_id=abc                    prefix of the trace or span_id
+//	attr.user_id=42                 log attribute, compared as numbers if both are
+//	resource.host.name!=web-1       resource attribute, != also matches if it is missing
+//	attr.user_id                    attribute is set
+//	timeout                         body, service, severity, trace ID or any attribute contains a word, ignoring case
+//
+// Comparisons are =, !=, =~, !~, >, >=, < and <=.
+func ParseLogs(q string) (LogFilter, error) {
+	toks, err := lex(q)
+	if err != nil {
+		return nil, err
+	}
+	p := &logParser{parser{toks: toks}}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorAt(t.pos, "unexpected %s", t)
+	}
+	return f, nil
+}
+
+type logParser struct{ parser }
+
+func (p *logParser) or() (LogFilter, error) {
+	f, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "OR", "||") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left := f
+		f = func(l *server.Log) bool { return left(l) || right(l) }
+	}
+	return f, nil
+}
+
+func (p *logParser) and() (LogFilter, error) {
+	f, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind == tokEOF || t.kind == tokRParen || isKeyword(t, "OR", "||") {
+			return f, nil
+		}
+		if isKeyword(t, "AND", "&&") {
+			p.next()
+		}
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left := f
+		f = func(l *server.Log) bool { return left(l) && right(l) }
+	}
+}
+
+func (p *logParser) not() (LogFilter, error) {
+	if !isKeyword(p.peek(), "NOT", "!") {
+		return p.primary()
+	}
+	p.next()
+	f, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	return func(l *server.Log) bool { return !f(l) }, nil
+}
+
+func (p *logParser) primary() (LogFilter, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if end := p.next(); end.kind != tokRParen {
+			return nil, errorAt(end.pos, "expected \")\" instead of %s", end)
+		}
+		return f, nil
+	case tokWord:
+		if op := p.peek(); op.kind == tokOp && isComparison(op.text) {
+			p.next()
+			return p.comparison(t, op.text)
+		}
+		if key, ok := strings.CutPrefix(t.text, "attr."); ok {
+			return func(l *server.Log) bool { _, ok := attr(l.Log.Attributes, key); return ok }, nil
+		}
+		if key, ok := strings.CutPrefix(t.text, "resource."); ok {
+			return func(l *server.Log) bool { _, ok := attr(l.ResourceLogs.Resource.GetAttributes(), key); return ok }, nil
+		}
+		return logContains(strings.ToLower(t.text)), nil
+	case tokString:
+		return logContains(strings.ToLower(t.text)), nil
+	}
+	return nil, errorAt(t.pos, "unexpected %s", t)
+}
+
+// comparison parses the value compared to field with op
+func (p *logParser) comparison(field token, op string) (LogFilter, error) {
+	v, t, err := p.value(op)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name := field.text; {
+	case name == "severity" || name == "level":
+		return severityComparison(op, v, t)
+	case name == "service.name" || name == "service":
+		return func(l *server.Log) bool {
+			s, ok := attr(l.ResourceLogs.Resource.GetAttributes(), "service.name")
+			return compare(op, s, ok, v)
+		}, nil
+	case name == "body":
+		return func(l *server.Log) bool { return compare(op, utils.AnyToString(l.Log.Body), l.Log.Body != nil, v) }, nil
+	case name == "trace_id" || name == "span_id":
+		id := func(l *server.Log) []byte { return l.Log.TraceId }
+		if name == "span_id" {
+			id = func(l *server.Log) []byte { return l.Log.SpanId }
+		}
+		return idComparison(op, v, t, id)
+	case strings.HasPrefix(name, "attr."):
+		key := strings.TrimPrefix(name, "attr.")
+		return func(l *server.Log) bool { s, ok := attr(l.Log.Attributes, key); return compare(op, s, ok, v) }, nil
+	case strings.HasPrefix(name, "resource."):
+		key := strings.TrimPrefix(name, "resource.")
+		return func(l *server.Log) bool {
+			s, ok := attr(l.ResourceLogs.Resource.GetAttributes(), key)
+			return compare(op, s, ok, v)
+		}, nil
+	}
+	return nil, errorAt(field.pos, "unknown field %q, expected eg. severity, service.name, body or attr.<key>", field.text)
+}
+
+// severityComparison compares severity numbers, where a level stands for its range, eg. >WARN is above WARN4.
+// Regexps match the severity text.
+func severityComparison(op string, v value, t token) (LogFilter, error) {
+	if v.re != nil {
+		return func(l *server.Log) bool { return compare(op, l.Log.SeverityText, true, v) }, nil
+	}
+	lo, hi, ok := SeverityRange(v.text)
+	if !ok {
+		return nil, errorAt(t.pos, "unknown severity %q, expected eg. DEBUG, WARN, ERROR4 or 1 to 24", v.text)
+	}
+	return func(l *server.Log) bool {
+		n := SeverityOf(l.Log)
+		switch op {
+		case "=":
+			return n >= lo && n <= hi
+		case "!=":
+			return n < lo || n > hi
+		case ">":
+			return n > hi
+		case ">=":
+			return n >= lo
+		case "<":
+			return n < lo
+		}
+		return n <= hi
+	}, nil
+}
+
+var severityLevels = map[string]logs.SeverityNumber{
+	"trace":    logs.SeverityNumber_SEVERITY_NUMBER_TRACE,
+	"debug":    logs.SeverityNumber_SEVERITY_NUMBER_DEBUG,
+	"info":     logs.SeverityNumber_SEVERITY_NUMBER_INFO,
+	"warn":     logs.SeverityNumber_SEVERITY_NUMBER_WARN,
+	"warning":  logs.SeverityNumber_SEVERITY_NUMBER_WARN,
+	"error":    logs.SeverityNumber_SEVERITY_NUMBER_ERROR,
+	"err":      logs.SeverityNumber_SEVERITY_NUMBER_ERROR,
+	"fatal":    logs.SeverityNumber_SEVERITY_NUMBER_FATAL,
+	"critical": logs.SeverityNumber_SEVERITY_NUMBER_FATAL,
+}
+
+// SeverityRange is the range of severity numbers of a level, eg. ERROR to ERROR4, or of a single one, eg. WARN2 or 14
+func SeverityRange(s string) (lo, hi logs.SeverityNumber, ok bool) {
+	s = strings.ToLower(s)
+	if n, ok := severityLevels[s]; ok {
+		return n, n + 3, true
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 1 && n <= 24 {
+		return logs.SeverityNumber(n), logs.SeverityNumber(n), true
+	}
+	if len(s) > 1 && s[len(s)-1] >= '2' && s[len(s)-1] <= '4' {
+		if n, ok := severityLevels[s[:len(s)-1]]; ok {
+			n += logs.SeverityNumber(s[len(s)-1] - '1')
+			return n, n, true
+		}
+	}
+	return 0, 0, false
+}
+
+// SeverityOf is the severity number of a log, or the one of its text if it has none
+func SeverityOf(l *logs.LogRecord) logs.SeverityNumber {
+	if l.SeverityNumber != logs.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED {
+		return l.SeverityNumber
+	}
+	n, _, _ := SeverityRange(l.SeverityText)
+	return n
+}
+
+// idComparison matches IDs by hex prefix, ignoring case
+func idComparison(op string, v value, t token, id func(*server.Log) []byte) (LogFilter, error) {
+	prefix := strings.ToLower(v.text)
+	switch op {
+	case "=", "!=":
+		return func(l *server.Log) bool {
+			b := id(l)
+			return (len(b) > 0 && strings.HasPrefix(hex.EncodeToString(b), prefix)) == (op == "=")
+		}, nil
+	case "=~", "!~":
+		return func(l *server.Log) bool { b := id(l); return compare(op, hex.EncodeToString(b), len(b) > 0, v) }, nil
+	}
+	return nil, errorAt(t.pos, "IDs can only be compared with =, !=, =~ or !~")
+}
+
+// logContains matches logs whose body, service, severity, trace ID or attributes contain word, in lower case
+func logContains(word string) LogFilter {
+	return func(l *server.Log) bool {
+		service, _ := attr(l.ResourceLogs.Resource.GetAttributes(), "service.name")
+		if containsFold(utils.AnyToString(l.Log.Body), word) || containsFold(service, word) ||
+			containsFold(l.Log.SeverityText, word) || strings.Contains(hex.EncodeToString(l.Log.TraceId), word) {
+			return true
+		}
+		for _, kvs := range [][]*v1.KeyValue{l.Log.Attributes, l.ScopeLogs.Scope.GetAttributes(), l.ResourceLogs.Resource.GetAttributes()} {
+			for _, kv := range kvs {
+				if containsFold(kv.Key+"="+utils.AnyToString(kv.Value), word) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+func attr(kvs []*v1.KeyValue, key string) (string, bool) {
+	for _, kv := range kvs {
+		if kv.Key == key {
+			return utils.AnyToString(kv.Value), true
+		}
+	}
+	return "", false
+}
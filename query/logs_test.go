@@ -0,0 +1,140 @@
+package query
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"pitr.ca/otelui/server"
+)
+
+func stringAttr(key, value string) *v1.KeyValue {
+	return &v1.KeyValue{Key: key, Value: &v1.AnyValue{Value: &v1.AnyValue_StringValue{StringValue: value}}}
+}
+
+func intAttr(key string, value int64) *v1.KeyValue {
+	return &v1.KeyValue{Key: key, Value: &v1.AnyValue{Value: &v1.AnyValue_IntValue{IntValue: value}}}
+}
+
+func testLog(service string, severity logs.SeverityNumber, body string, attrs ...*v1.KeyValue) *server.Log {
+	rl := &logs.ResourceLogs{Resource: &resource.Resource{Attributes: []*v1.KeyValue{stringAttr("service.name", service)}}}
+	return &server.Log{
+		Log: &logs.LogRecord{
+			SeverityNumber: severity,
+			Body:           &v1.AnyValue{Value: &v1.AnyValue_StringValue{StringValue: body}},
+			Attributes:     attrs,
+			TraceId:        []byte{0xab, 0xcd, 0xef, 0x01},
+		},
+		ResourceLogs: rl,
+		ScopeLogs:    &logs.ScopeLogs{},
+	}
+}
+
+var testLogs = []*server.Log{
+	testLog("api", logs.SeverityNumber_SEVERITY_NUMBER_INFO, "request done", intAttr("user_id", 42)),
+	testLog("api", logs.SeverityNumber_SEVERITY_NUMBER_WARN, "request timeout after 5s", intAttr("user_id", 7)),
+	testLog("db", logs.SeverityNumber_SEVERITY_NUMBER_ERROR2, "connection refused"),
+	// no resource, scope, body or severity number
+	{Log: &logs.LogRecord{SeverityText: "fatal"}, ResourceLogs: &logs.ResourceLogs{}, ScopeLogs: &logs.ScopeLogs{}},
+}
+
+func TestParseLogs(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []int // indexes of the matching testLogs
+	}{
+		{`severity>=WARN`, []int{1, 2, 3}},
+		{`severity=error`, []int{2}},
+		{`severity=ERROR2`, []int{2}},
+		{`severity>WARN`, []int{2, 3}},
+		{`level<=info`, []int{0}},
+		{`severity=~"^fat"`, []int{3}},
+		{`service.name="api"`, []int{0, 1}},
+		{`service!=api`, []int{2, 3}},
+		{`body=~"timeout.*"`, []int{1}},
+		{`body!~"^request"`, []int{2, 3}},
+		{`attr.user_id=42`, []int{0}},
+		{`attr.user_id>10`, []int{0}},
+		{`attr.user_id<10`, []int{1}},
+		{`attr.user_id`, []int{0, 1}},
+		{`resource.service.name=db`, []int{2}},
+		{`trace_id=ABCD`, []int{0, 1, 2}},
+		{`trace_id!=abcd`, []int{3}},
+		{`span_id=ab`, nil},
+		{`timeout`, []int{1}},
+		{`"connection refused"`, []int{2}},
+		{`REQUEST`, []int{0, 1}},
+		{`service=api severity>=warn`, []int{1}},
+		{`service=api AND severity>=warn`, []int{1}},
+		{`service=db OR attr.user_id=42`, []int{0, 2}},
+		{`service=db || attr.user_id=42`, []int{0, 2}},
+		{`NOT service=api`, []int{2, 3}},
+		{`!service=api`, []int{2, 3}},
+		{`service=api (attr.user_id=42 OR body=~"timeout.*") NOT trace_id=abc`, nil},
+		{`(service=db OR severity=fatal) NOT body=~refused`, []int{3}},
+		{`not found`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			f, err := ParseLogs(tt.query)
+			if err != nil {
+				t.Fatalf("ParseLogs(%q) error: %v", tt.query, err)
+			}
+			var got []int
+			for i, l := range testLogs {
+				if f(l) {
+					got = append(got, i)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseLogs(%q) matched %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLogsErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`body="timeout`, "at 6: unterminated string"},
+		{`(service=api`, "at 13: expected \")\" instead of end of query"},
+		{`service=api)`, "at 12: unexpected \")\""},
+		{`severity=`, "at 10: expected a value after = instead of end of query"},
+		{`severity=loud`, "at 10: unknown severity \"loud\""},
+		{`body=~"("`, "at 7: invalid regexp"},
+		{`foo=bar`, "at 1: unknown field \"foo\""},
+		{`trace_id>abc`, "at 10: IDs can only be compared"},
+		{`service=api &`, "at 13: unexpected '&'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseLogs(tt.query)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("ParseLogs(%q) error %v, want %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeverityOf(t *testing.T) {
+	tests := []struct {
+		log  *logs.LogRecord
+		want logs.SeverityNumber
+	}{
+		{&logs.LogRecord{SeverityNumber: logs.SeverityNumber_SEVERITY_NUMBER_WARN3, SeverityText: "info"}, logs.SeverityNumber_SEVERITY_NUMBER_WARN3},
+		{&logs.LogRecord{SeverityText: "Warning"}, logs.SeverityNumber_SEVERITY_NUMBER_WARN},
+		{&logs.LogRecord{SeverityText: "ERROR2"}, logs.SeverityNumber_SEVERITY_NUMBER_ERROR2},
+		{&logs.LogRecord{SeverityText: "verbose"}, logs.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED},
+	}
+	for _, tt := range tests {
+		if got := SeverityOf(tt.log); got != tt.want {
+			t.Errorf("SeverityOf(%v) = %v, want %v", tt.log, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Package query parses filter expressions for logs, and TraceQL-like queries for traces, eg.
+//
+//	severity>=WARN service.name="api" (attr.user_id=42 OR body=~"timeout.*") NOT trace_id=abc
+//	{ name="db.query" && duration>100ms } && { status=error }
+package query
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokWord             // unquoted, eg. a field, a value or a bare word
+	tokString           // quoted, with escapes resolved
+	tokOp               // comparison or logical operator
+	tokLParen
+	tokRParen
+	tokLBrace
+	tokRBrace
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // of the first character, from 1
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators by decreasing length, so the longest one is lexed
+var operators = []string{"=~", "!~", "!=", ">=", "<=", "&&", "||", ">>", "<<", "=", ">", "<", "!", "~"}
+
+// opChars end a word
+const opChars = "=!<>~&|"
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')' || c == '{' || c == '}':
+			kind := map[byte]tokenKind{'(': tokLParen, ')': tokRParen, '{': tokLBrace, '}': tokRBrace}[c]
+			toks = append(toks, token{kind, string(c), i + 1})
+			i++
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, errorAt(i+1, "unterminated string")
+			}
+			toks = append(toks, token{tokString, b.String(), i + 1})
+			i = j + 1
+		case strings.IndexByte(opChars, c) >= 0:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, errorAt(i+1, "unexpected %q", c)
+			}
+			toks = append(toks, token{tokOp, op, i + 1})
+			i += len(op)
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n(){}\""+opChars, rune(s[j])) {
+				j++
+			}
+			toks = append(toks, token{tokWord, s[i:j], i + 1})
+			i = j
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(s) + 1}), nil
+}
+
+// parser reads tokens, the last of which is tokEOF
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// value reads the value compared with op
+func (p *parser) value(op string) (value, token, error) {
+	t := p.next()
+	if t.kind != tokWord && t.kind != tokString {
+		return value{}, t, errorAt(t.pos, "expected a value after %s instead of %s", op, t)
+	}
+	v, err := newValue(op, t)
+	return v, t, err
+}
+
+func errorAt(pos int, format string, args ...any) error {
+	return fmt.Errorf("at %d: %s", pos, fmt.Sprintf(format, args...))
+}
+
+// isComparison tells whether op compares a field to a value
+func isComparison(op string) bool {
+	switch op {
+	case "=", "!=", "=~", "!~", ">", ">=", "<", "<=":
+		return true
+	}
+	return false
+}
+
+// value is the right hand side of a comparison
+type value struct {
+	text string
+	re   *regexp.Regexp // for =~ and !~
+}
+
+func newValue(op string, t token) (value, error) {
+	v := value{text: t.text}
+	if op == "=~" || op == "!~" {
+		re, err := regexp.Compile(t.text)
+		if err != nil {
+			return v, errorAt(t.pos, "invalid regexp: %v", err)
+		}
+		v.re = re
+	}
+	return v, nil
+}
+
+// compare compares got, if found, to v with op. Values that are both numbers are compared as numbers.
+// A missing value only matches != and !~.
+func compare(op, got string, found bool, v value) bool {
+	if !found {
+		return op == "!=" || op == "!~"
+	}
+	switch op {
+	case "=~":
+		return v.re.MatchString(got)
+	case "!~":
+		return !v.re.MatchString(got)
+	}
+
+	a, aerr := strconv.ParseFloat(got, 64)
+	b, berr := strconv.ParseFloat(v.text, 64)
+	if aerr == nil && berr == nil {
+		return ordered(op, cmp.Compare(a, b))
+	}
+	return ordered(op, strings.Compare(got, v.text))
+}
+
+// ordered tells whether the result of a comparison satisfies op
+func ordered(op string, c int) bool {
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+// containsFold tells whether s contains substr, which is in lower case, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), substr)
+}
+
+// isKeyword tells whether t is the logical operator kw, in upper case so that eg. "not found" is a search for words
+func isKeyword(t token, kw string, alias string) bool {
+	return (t.kind == tokWord && t.text == kw) || (t.kind == tokOp && t.text == alias)
+}
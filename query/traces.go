@@ -0,0 +1,421 @@
+package query
+
+import (
+	"cmp"
+	"strings"
+	"time"
+
+	traces "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"pitr.ca/otelui/server"
+)
+
+// TraceFilter tells whether a trace matches a query
+type TraceFilter func(*server.Trace) bool
+
+// ParseTraces parses a TraceQL-like query, matching traces that have spans with given properties:
+//
+//	{ name="db.query" && duration>100ms } && { status=error }
+//
+// A spanset { ... } is the spans matching conditions combined with &&, || and !, or all spans if empty:
+//
+//	name="GET /cart"                span name, or regexp with =~
+//	status=error                    unset, ok or error
+//	kind=server                     internal, server, client, producer or consumer
+//	duration>100ms                  compared with >, >=, <, <=, = and !=
+//	.http.route="/cart"             span attribute, or else resource attribute
+//	span.http.route, resource.service.name
+//
+// Spansets combine into the spans of the right hand side
+// with a descendant (>>), child (>), ancestor (<<), parent (<) or sibling (~) on the left,
+// and into traces where both (&&) or either (||) spansets match.
+// A trace matches if the resulting spanset isn't empty.
+func ParseTraces(q string) (TraceFilter, error) {
+	toks, err := lex(q)
+	if err != nil {
+		return nil, err
+	}
+	p := &traceParser{parser{toks: toks}}
+	s, err := p.spansetOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorAt(t.pos, "unexpected %s", t)
+	}
+	return func(t *server.Trace) bool {
+		for _, ok := range s(newTraceIndex(t)) {
+			if ok {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// traceIndex are the spans of a trace with the index of their parent, -1 for roots or missing parents
+type traceIndex struct {
+	spans  []*server.Span
+	parent []int
+}
+
+func newTraceIndex(t *server.Trace) *traceIndex {
+	idx := &traceIndex{spans: t.Spans, parent: make([]int, len(t.Spans))}
+	byID := make(map[string]int, len(t.Spans))
+	for i, s := range t.Spans {
+		byID[string(s.Span.SpanId)] = i
+	}
+	for i, s := range t.Spans {
+		idx.parent[i] = -1
+		if p, ok := byID[string(s.Span.ParentSpanId)]; ok && len(s.Span.ParentSpanId) > 0 && p != i {
+			idx.parent[i] = p
+		}
+	}
+	return idx
+}
+
+// ancestors calls f with the parent of span i, its parent, and so on until f returns false
+func (idx *traceIndex) ancestors(i int, f func(int) bool) {
+	// bounded in case spans are their own ancestors
+	for range idx.spans {
+		if i = idx.parent[i]; i < 0 || !f(i) {
+			return
+		}
+	}
+}
+
+// spanset tells which spans of a trace are in the set
+type spanset func(*traceIndex) []bool
+
+// spanCondition tells whether a span matches the conditions of a spanset
+type spanCondition func(*server.Span) bool
+
+type traceParser struct{ parser }
+
+func (p *traceParser) spansetOr() (spanset, error) {
+	left, err := p.spansetAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && t.text == "||"; t = p.peek() {
+		p.next()
+		right, err := p.spansetAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = combine(left, right, func(l, r []bool) []bool {
+			for i := range l {
+				l[i] = l[i] || r[i]
+			}
+			return l
+		})
+	}
+	return left, nil
+}
+
+func (p *traceParser) spansetAnd() (spanset, error) {
+	left, err := p.structural()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && t.text == "&&"; t = p.peek() {
+		p.next()
+		right, err := p.structural()
+		if err != nil {
+			return nil, err
+		}
+		left = combine(left, right, func(l, r []bool) []bool {
+			if !nonEmpty(l) || !nonEmpty(r) {
+				return make([]bool, len(l))
+			}
+			for i := range l {
+				l[i] = l[i] || r[i]
+			}
+			return l
+		})
+	}
+	return left, nil
+}
+
+// structural parses spansets related by their position in the trace, from left to right
+func (p *traceParser) structural() (spanset, error) {
+	left, err := p.spansetPrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokOp {
+			return left, nil
+		}
+		var rel func(idx *traceIndex, l, r []bool) []bool
+		switch t.text {
+		case ">>":
+			rel = descendants
+		case ">":
+			rel = children
+		case "<<":
+			rel = ancestors
+		case "<":
+			rel = parents
+		case "~":
+			rel = siblings
+		default:
+			return left, nil
+		}
+		p.next()
+		right, err := p.spansetPrimary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(idx *traceIndex) []bool { return rel(idx, l(idx), right(idx)) }
+	}
+}
+
+func (p *traceParser) spansetPrimary() (spanset, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		s, err := p.spansetOr()
+		if err != nil {
+			return nil, err
+		}
+		if end := p.next(); end.kind != tokRParen {
+			return nil, errorAt(end.pos, "expected \")\" instead of %s", end)
+		}
+		return s, nil
+	case tokLBrace:
+		cond := spanCondition(func(*server.Span) bool { return true })
+		if p.peek().kind != tokRBrace {
+			var err error
+			if cond, err = p.conditionOr(); err != nil {
+				return nil, err
+			}
+		}
+		if end := p.next(); end.kind != tokRBrace {
+			return nil, errorAt(end.pos, "expected \"}\" instead of %s", end)
+		}
+		return func(idx *traceIndex) []bool {
+			res := make([]bool, len(idx.spans))
+			for i, s := range idx.spans {
+				res[i] = cond(s)
+			}
+			return res
+		}, nil
+	}
+	return nil, errorAt(t.pos, "expected \"{\" instead of %s", t)
+}
+
+func (p *traceParser) conditionOr() (spanCondition, error) {
+	left, err := p.conditionAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && t.text == "||"; t = p.peek() {
+		p.next()
+		right, err := p.conditionAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(s *server.Span) bool { return l(s) || right(s) }
+	}
+	return left, nil
+}
+
+func (p *traceParser) conditionAnd() (spanCondition, error) {
+	left, err := p.conditionNot()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && t.text == "&&"; t = p.peek() {
+		p.next()
+		right, err := p.conditionNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(s *server.Span) bool { return l(s) && right(s) }
+	}
+	return left, nil
+}
+
+func (p *traceParser) conditionNot() (spanCondition, error) {
+	if t := p.peek(); t.kind != tokOp || t.text != "!" {
+		return p.conditionPrimary()
+	}
+	p.next()
+	c, err := p.conditionNot()
+	if err != nil {
+		return nil, err
+	}
+	return func(s *server.Span) bool { return !c(s) }, nil
+}
+
+func (p *traceParser) conditionPrimary() (spanCondition, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		c, err := p.conditionOr()
+		if err != nil {
+			return nil, err
+		}
+		if end := p.next(); end.kind != tokRParen {
+			return nil, errorAt(end.pos, "expected \")\" instead of %s", end)
+		}
+		return c, nil
+	case tokWord:
+		op := p.next()
+		if op.kind != tokOp || !isComparison(op.text) {
+			return nil, errorAt(op.pos, "expected a comparison after %s instead of %s", t, op)
+		}
+		return p.spanComparison(t, op.text)
+	}
+	return nil, errorAt(t.pos, "expected a condition instead of %s", t)
+}
+
+var (
+	statusCodes = map[string]traces.Status_StatusCode{
+		"unset": traces.Status_STATUS_CODE_UNSET,
+		"ok":    traces.Status_STATUS_CODE_OK,
+		"error": traces.Status_STATUS_CODE_ERROR,
+	}
+	spanKinds = map[string]traces.Span_SpanKind{
+		"unspecified": traces.Span_SPAN_KIND_UNSPECIFIED,
+		"internal":    traces.Span_SPAN_KIND_INTERNAL,
+		"server":      traces.Span_SPAN_KIND_SERVER,
+		"client":      traces.Span_SPAN_KIND_CLIENT,
+		"producer":    traces.Span_SPAN_KIND_PRODUCER,
+		"consumer":    traces.Span_SPAN_KIND_CONSUMER,
+	}
+)
+
+// spanComparison parses the value compared to field with op
+func (p *traceParser) spanComparison(field token, op string) (spanCondition, error) {
+	v, t, err := p.value(op)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name := field.text; {
+	case name == "name":
+		return func(s *server.Span) bool { return compare(op, s.Span.Name, true, v) }, nil
+	case name == "status":
+		code, ok := statusCodes[strings.ToLower(v.text)]
+		if !ok || (op != "=" && op != "!=") {
+			return nil, errorAt(t.pos, "expected status = or != unset, ok or error")
+		}
+		return func(s *server.Span) bool { return (s.Span.Status.GetCode() == code) == (op == "=") }, nil
+	case name == "kind":
+		kind, ok := spanKinds[strings.ToLower(v.text)]
+		if !ok || (op != "=" && op != "!=") {
+			return nil, errorAt(t.pos, "expected kind = or != internal, server, client, producer or consumer")
+		}
+		return func(s *server.Span) bool { return (s.Span.Kind == kind) == (op == "=") }, nil
+	case name == "duration":
+		d, err := time.ParseDuration(v.text)
+		if err != nil || v.re != nil {
+			return nil, errorAt(t.pos, "expected a duration, eg. 100ms, instead of %s", t)
+		}
+		return func(s *server.Span) bool {
+			return ordered(op, cmp.Compare(time.Duration(s.Span.EndTimeUnixNano-s.Span.StartTimeUnixNano), d))
+		}, nil
+	case strings.HasPrefix(name, "span."):
+		key := strings.TrimPrefix(name, "span.")
+		return func(s *server.Span) bool { a, ok := attr(s.Span.Attributes, key); return compare(op, a, ok, v) }, nil
+	case strings.HasPrefix(name, "resource."):
+		key := strings.TrimPrefix(name, "resource.")
+		return func(s *server.Span) bool {
+			a, ok := attr(s.Resource.GetAttributes(), key)
+			return compare(op, a, ok, v)
+		}, nil
+	case strings.HasPrefix(name, ".") && len(name) > 1:
+		key := name[1:]
+		return func(s *server.Span) bool {
+			a, ok := attr(s.Span.Attributes, key)
+			if !ok {
+				a, ok = attr(s.Resource.GetAttributes(), key)
+			}
+			return compare(op, a, ok, v)
+		}, nil
+	}
+	return nil, errorAt(field.pos, "unknown field %q, expected eg. name, status, kind, duration or .<attribute>", field.text)
+}
+
+// combine applies op to the spans of two spansets
+func combine(left, right spanset, op func(l, r []bool) []bool) spanset {
+	return func(idx *traceIndex) []bool { return op(left(idx), right(idx)) }
+}
+
+func nonEmpty(set []bool) bool {
+	for _, ok := range set {
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// descendants are the spans of r with an ancestor in l
+func descendants(idx *traceIndex, l, r []bool) []bool {
+	res := make([]bool, len(r))
+	for i := range r {
+		if r[i] {
+			idx.ancestors(i, func(a int) bool { res[i] = l[a]; return !res[i] })
+		}
+	}
+	return res
+}
+
+// children are the spans of r with their parent in l
+func children(idx *traceIndex, l, r []bool) []bool {
+	res := make([]bool, len(r))
+	for i := range r {
+		res[i] = r[i] && idx.parent[i] >= 0 && l[idx.parent[i]]
+	}
+	return res
+}
+
+// ancestors are the spans of r with a descendant in l
+func ancestors(idx *traceIndex, l, r []bool) []bool {
+	res := make([]bool, len(r))
+	for i := range l {
+		if l[i] {
+			idx.ancestors(i, func(a int) bool { res[a] = res[a] || r[a]; return true })
+		}
+	}
+	return res
+}
+
+// parents are the spans of r with a child in l
+func parents(idx *traceIndex, l, r []bool) []bool {
+	res := make([]bool, len(r))
+	for i := range l {
+		if p := idx.parent[i]; l[i] && p >= 0 && r[p] {
+			res[p] = true
+		}
+	}
+	return res
+}
+
+// siblings are the spans of r with another span of the same parent in l
+func siblings(idx *traceIndex, l, r []bool) []bool {
+	inL := map[int]int{} // spans of l by parent
+	for i := range l {
+		if p := idx.parent[i]; l[i] && p >= 0 {
+			inL[p]++
+		}
+	}
+	res := make([]bool, len(r))
+	for i := range r {
+		if p := idx.parent[i]; r[i] && p >= 0 {
+			n := inL[p]
+			if l[i] {
+				n-- // not its own sibling
+			}
+			res[i] = n > 0
+		}
+	}
+	return res
+}
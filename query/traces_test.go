@@ -0,0 +1,150 @@
+package query
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	traces "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"pitr.ca/otelui/server"
+)
+
+type testSpan struct {
+	id, parent byte
+	name       string
+	kind       traces.Span_SpanKind
+	status     traces.Status_StatusCode
+	duration   time.Duration
+	attrs      []*v1.KeyValue
+}
+
+func testTrace(service string, spans ...testSpan) *server.Trace {
+	res := &resource.Resource{Attributes: []*v1.KeyValue{stringAttr("service.name", service)}}
+	t := &server.Trace{}
+	for _, s := range spans {
+		span := &traces.Span{
+			SpanId:            []byte{s.id},
+			Name:              s.name,
+			Kind:              s.kind,
+			Status:            &traces.Status{Code: s.status},
+			StartTimeUnixNano: 1000,
+			EndTimeUnixNano:   1000 + uint64(s.duration),
+			Attributes:        s.attrs,
+		}
+		if s.parent != 0 {
+			span.ParentSpanId = []byte{s.parent}
+		}
+		t.Spans = append(t.Spans, &server.Span{Span: span, Resource: res})
+	}
+	return t
+}
+
+var testTraces = []*server.Trace{
+	testTrace("frontend",
+		testSpan{id: 1, name: "GET /cart", kind: traces.Span_SPAN_KIND_SERVER, duration: 200 * time.Millisecond,
+			attrs: []*v1.KeyValue{stringAttr("http.route", "/cart")}},
+		testSpan{id: 2, parent: 1, name: "db.query", kind: traces.Span_SPAN_KIND_CLIENT,
+			status: traces.Status_STATUS_CODE_ERROR, duration: 150 * time.Millisecond},
+		testSpan{id: 3, parent: 2, name: "db.connect", duration: 10 * time.Millisecond},
+		testSpan{id: 4, parent: 1, name: "cache.get", kind: traces.Span_SPAN_KIND_CLIENT, duration: 5 * time.Millisecond},
+	),
+	testTrace("frontend",
+		testSpan{id: 1, name: "GET /health", kind: traces.Span_SPAN_KIND_SERVER, duration: time.Millisecond,
+			status: traces.Status_STATUS_CODE_OK},
+	),
+	testTrace("worker",
+		testSpan{id: 1, name: "db.query", duration: 300 * time.Millisecond},
+		testSpan{id: 2, parent: 1, name: "GET /cart", kind: traces.Span_SPAN_KIND_INTERNAL},
+		// its parent is missing
+		testSpan{id: 3, parent: 9, name: "db.query", duration: 300 * time.Millisecond},
+	),
+}
+
+func TestParseTraces(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []int // indexes of the matching testTraces
+	}{
+		{`{}`, []int{0, 1, 2}},
+		{`{ name="db.query" }`, []int{0, 2}},
+		{`{ name=~"^GET" }`, []int{0, 1, 2}},
+		{`{ name!="GET /health" }`, []int{0, 2}},
+		{`{ name="db.query" && duration>200ms }`, []int{2}},
+		{`{ duration<=1ms }`, []int{1, 2}},
+		{`{ status=error }`, []int{0}},
+		{`{ status=ok }`, []int{1}},
+		{`{ status!=unset }`, []int{0, 1}},
+		{`{ kind=server }`, []int{0, 1}},
+		{`{ kind=internal }`, []int{2}},
+		{`{ .http.route="/cart" }`, []int{0}},
+		{`{ span.http.route="/cart" }`, []int{0}},
+		{`{ .service.name=worker }`, []int{2}},
+		{`{ resource.service.name=frontend }`, []int{0, 1}},
+		{`{ span.service.name=frontend }`, nil},
+		{`{ !(name=~"^GET") && duration<10ms }`, []int{0}},
+		{`{ name="cache.get" || status=ok }`, []int{0, 1}},
+		{`{ name="GET /cart" } >> { name="db.connect" }`, []int{0}},
+		{`{ name="GET /cart" } > { name="db.connect" }`, nil},
+		{`{ name="db.query" } > { name="db.connect" }`, []int{0}},
+		{`{ name="db.query" } > { name="GET /cart" }`, []int{2}},
+		{`{ name="db.connect" } << { name="GET /cart" }`, []int{0}},
+		{`{ name="db.connect" } < { name="db.query" }`, []int{0}},
+		{`{ name="db.connect" } < { name="GET /cart" }`, nil},
+		{`{ name="cache.get" } ~ { name="db.query" }`, []int{0}},
+		{`{ name="db.query" } ~ { name="db.query" }`, nil},
+		{`{ kind=server } > { kind=client } > { name="db.connect" }`, []int{0}},
+		{`{ status=error } && { name="cache.get" }`, []int{0}},
+		{`{ status=error } && { name="GET /health" }`, nil},
+		{`{ status=error } || { name="GET /health" }`, []int{0, 1}},
+		{`({ status=error } || { name="GET /health" }) && { kind=server }`, []int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			f, err := ParseTraces(tt.query)
+			if err != nil {
+				t.Fatalf("ParseTraces(%q) error: %v", tt.query, err)
+			}
+			var got []int
+			for i, tr := range testTraces {
+				if f(tr) {
+					got = append(got, i)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseTraces(%q) matched %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTracesErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`name="db.query"`, "at 1: expected \"{\" instead of \"name\""},
+		{`{ name="db.query"`, "at 18: expected \"}\" instead of end of query"},
+		{`{ name }`, "at 8: expected a comparison after \"name\" instead of \"}\""},
+		{`{ status=broken }`, "at 10: expected status = or != unset, ok or error"},
+		{`{ status>ok }`, "at 10: expected status = or != unset, ok or error"},
+		{`{ kind=remote }`, "at 8: expected kind = or !="},
+		{`{ duration>fast }`, "at 12: expected a duration, eg. 100ms, instead of \"fast\""},
+		{`{ foo=bar }`, "at 3: unknown field \"foo\""},
+		{`{ . = 1 }`, "at 3: unknown field \".\""},
+		{`{} && `, "at 7: expected \"{\" instead of end of query"},
+		{`({}`, "at 4: expected \")\" instead of end of query"},
+		{`{} {}`, "at 4: unexpected \"{\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseTraces(tt.query)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("ParseTraces(%q) error %v, want %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,442 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	traces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"pitr.ca/otelui/utils"
+)
+
+// Pagination of API responses, with limit and offset query parameters
+const (
+	defaultAPILimit = 100
+	maxAPILimit     = 10000
+)
+
+// apiPage is a page of API results
+type apiPage struct {
+	Total  int   `json:"total"`
+	Offset int   `json:"offset"`
+	Limit  int   `json:"limit"`
+	Items  []any `json:"items"`
+}
+
+type apiLog struct {
+	Received time.Time       `json:"received"`
+	Log      json.RawMessage `json:"log"`
+	Resource json.RawMessage `json:"resource"`
+	Scope    json.RawMessage `json:"scope"`
+}
+
+type apiTrace struct {
+	TraceID  string    `json:"traceId"`
+	Received time.Time `json:"received"`
+	Spans    []apiSpan `json:"spans"`
+}
+
+type apiSpan struct {
+	Span     json.RawMessage `json:"span"`
+	Resource json.RawMessage `json:"resource"`
+	Scope    json.RawMessage `json:"scope"`
+}
+
+type apiDatapoints struct {
+	Series     string         `json:"series"`
+	Times      []uint64       `json:"timesUnixNano"`
+	Values     []apiFloat     `json:"values"`
+	Histograms []apiHistogram `json:"histograms,omitempty"`
+}
+
+type apiHistogram struct {
+	Cumulative bool        `json:"cumulative"`
+	Count      uint64      `json:"count"`
+	Sum        apiFloat    `json:"sum"`
+	Min        *apiFloat   `json:"min,omitempty"`
+	Max        *apiFloat   `json:"max,omitempty"`
+	Buckets    []apiBucket `json:"buckets"`
+}
+
+type apiBucket struct {
+	Lower apiFloat `json:"lower"`
+	Upper apiFloat `json:"upper"`
+	Count uint64   `json:"count"`
+}
+
+// apiFloat is encoded like in OTLP/JSON, with NaN and infinities as "NaN", "Infinity" and "-Infinity"
+type apiFloat float64
+
+func (f apiFloat) MarshalJSON() ([]byte, error) {
+	switch v := float64(f); {
+	case math.IsNaN(v):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(v, 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(v, -1):
+		return []byte(`"-Infinity"`), nil
+	default:
+		return json.Marshal(v)
+	}
+}
+
+// reportedFloat is nil for the NaN of values that weren't reported
+func reportedFloat(v float64) *apiFloat {
+	if math.IsNaN(v) {
+		return nil
+	}
+	f := apiFloat(v)
+	return &f
+}
+
+type apiPayload struct {
+	Received time.Time       `json:"received"`
+	Signal   string          `json:"signal"`
+	Records  int             `json:"records"`
+	Size     int             `json:"size"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// apiHandler serves stored telemetry as JSON, with OTLP/JSON for the telemetry itself:
+//
+//	GET /api/logs        ?service= &severity=warn &trace_id= &body= &attr.<key>= &since=
+//	GET /api/traces      ?service= &name= &trace_id= &status=error &attr.<key>= &since=
+//	GET /api/metrics     ?name= &service= &attr.<key>=
+//	GET /api/datapoints  ?series=<as listed by /api/metrics>
+//	GET /api/payloads    ?signal=logs|traces|metrics &since=
+//
+// Lists are paginated with limit and offset.
+func apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/logs", apiLogs)
+	mux.HandleFunc("GET /api/traces", apiTraces)
+	mux.HandleFunc("GET /api/metrics", apiMetrics)
+	mux.HandleFunc("GET /api/datapoints", apiDatapointsOf)
+	mux.HandleFunc("GET /api/payloads", apiPayloads)
+	return mux
+}
+
+func apiLogs(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	f, err := parseAPIFilter(q)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+	var minSeverity logs.SeverityNumber
+	if s := q.Get("severity"); s != "" {
+		if minSeverity = parseSeverity(s); minSeverity == 0 {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				apiError(w, http.StatusBadRequest, fmt.Errorf("unknown severity %q", s))
+				return
+			}
+			minSeverity = logs.SeverityNumber(n)
+		}
+	}
+	traceID, body := strings.ToLower(q.Get("trace_id")), q.Get("body")
+
+	var matched []*Log
+	for _, l := range GetLogs() {
+		if l.Received.Before(f.since) || l.Log.SeverityNumber < minSeverity ||
+			!strings.HasPrefix(hex.EncodeToString(l.Log.TraceId), traceID) ||
+			!strings.Contains(utils.AnyToString(l.Log.Body), body) ||
+			!f.matches(l.ResourceLogs.Resource.GetAttributes(), l.Log.Attributes) {
+			continue
+		}
+		matched = append(matched, l)
+	}
+
+	writePage(w, req, len(matched), func(i int) (any, error) {
+		l := matched[i]
+		item := apiLog{Received: l.Received}
+		err := errors.Join(
+			marshalInto(&item.Log, l.Log),
+			marshalInto(&item.Resource, l.ResourceLogs.Resource),
+			marshalInto(&item.Scope, l.ScopeLogs.Scope),
+		)
+		return item, err
+	})
+}
+
+func apiTraces(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	f, err := parseAPIFilter(q)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+	traceID, name, status := strings.ToLower(q.Get("trace_id")), q.Get("name"), strings.ToLower(q.Get("status"))
+
+	var matched []*Trace
+	for _, t := range GetTraces() {
+		if t.Received.Before(f.since) || !strings.HasPrefix(t.TraceID, traceID) {
+			continue
+		}
+		// a trace matches if any of its spans does
+		for _, s := range t.Spans {
+			if (name == "" || s.Span.Name == name) &&
+				(status == "" || strings.EqualFold(strings.TrimPrefix(s.Span.Status.GetCode().String(), "STATUS_CODE_"), status)) &&
+				f.matches(s.Resource.GetAttributes(), s.Span.Attributes) {
+				matched = append(matched, t)
+				break
+			}
+		}
+	}
+
+	writePage(w, req, len(matched), func(i int) (any, error) {
+		t := matched[i]
+		item := apiTrace{TraceID: t.TraceID, Received: t.Received, Spans: make([]apiSpan, len(t.Spans))}
+		for j, s := range t.Spans {
+			if err := errors.Join(
+				marshalInto(&item.Spans[j].Span, s.Span),
+				marshalInto(&item.Spans[j].Resource, s.Resource),
+				marshalInto(&item.Spans[j].Scope, s.Scope),
+			); err != nil {
+				return nil, err
+			}
+		}
+		return item, nil
+	})
+}
+
+func apiMetrics(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	name := q.Get("name")
+	// series are named like serializeAttributes does, so attributes are matched as key="value"
+	var wants []string
+	for key, values := range q {
+		switch {
+		case key == "service":
+			wants = append(wants, fmt.Sprintf("service.name=%q", values[0]))
+		case strings.HasPrefix(key, "attr."):
+			wants = append(wants, fmt.Sprintf("%s=%q", strings.TrimPrefix(key, "attr."), values[0]))
+		}
+	}
+
+	series := GetMetrics()
+	sort.Strings(series)
+	var matched []string
+	for _, s := range series {
+		metric, attrs, _ := strings.Cut(s, "{")
+		if name != "" && metric != name {
+			continue
+		}
+		ok := true
+		for _, want := range wants {
+			if !strings.Contains(","+attrs, ","+want) {
+				ok = false
+			}
+		}
+		if ok {
+			matched = append(matched, s)
+		}
+	}
+
+	writePage(w, req, len(matched), func(i int) (any, error) { return matched[i], nil })
+}
+
+func apiDatapointsOf(w http.ResponseWriter, req *http.Request) {
+	series := req.URL.Query().Get("series")
+	if series == "" {
+		apiError(w, http.StatusBadRequest, fmt.Errorf("missing series"))
+		return
+	}
+	dps := GetDatapoints(series)
+	if dps == nil {
+		apiError(w, http.StatusNotFound, fmt.Errorf("unknown series %q", series))
+		return
+	}
+	res := apiDatapoints{Series: series, Times: dps.Times, Values: make([]apiFloat, len(dps.Values))}
+	for i, v := range dps.Values {
+		res.Values[i] = apiFloat(v)
+	}
+	for _, h := range dps.Histograms {
+		ah := apiHistogram{Cumulative: h.Cumulative, Count: h.Count, Sum: apiFloat(h.Sum),
+			Min: reportedFloat(h.Min), Max: reportedFloat(h.Max), Buckets: make([]apiBucket, len(h.Buckets))}
+		for i, b := range h.Buckets {
+			ah.Buckets[i] = apiBucket{Lower: apiFloat(b.Lower), Upper: apiFloat(b.Upper), Count: b.Count}
+		}
+		res.Histograms = append(res.Histograms, ah)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func apiPayloads(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	f, err := parseAPIFilter(q)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+	signal := q.Get("signal")
+
+	var matched []*Payload
+	for _, p := range GetPayloads() {
+		if !p.Received.Before(f.since) && (signal == "" || payloadSignal(p) == signal) {
+			matched = append(matched, p)
+		}
+	}
+
+	writePage(w, req, len(matched), func(i int) (any, error) {
+		p := matched[i]
+		item := apiPayload{Received: p.Received, Signal: payloadSignal(p), Records: p.Num, Size: p.Size}
+		resources, err := payloadJSON(p)
+		if err != nil {
+			return nil, err
+		}
+		item.Payload, err = json.Marshal(resources)
+		return item, err
+	})
+}
+
+func payloadSignal(p *Payload) string {
+	switch p.Payload.(type) {
+	case []*logs.ResourceLogs:
+		return "logs"
+	case []*traces.ResourceSpans:
+		return "traces"
+	case []*metrics.ResourceMetrics:
+		return "metrics"
+	}
+	return ""
+}
+
+// payloadJSON encodes the resources of a payload as OTLP/JSON
+func payloadJSON(p *Payload) ([]json.RawMessage, error) {
+	var ms []proto.Message
+	switch pp := p.Payload.(type) {
+	case []*logs.ResourceLogs:
+		for _, r := range pp {
+			ms = append(ms, r)
+		}
+	case []*traces.ResourceSpans:
+		for _, r := range pp {
+			ms = append(ms, r)
+		}
+	case []*metrics.ResourceMetrics:
+		for _, r := range pp {
+			ms = append(ms, r)
+		}
+	}
+	res := make([]json.RawMessage, len(ms))
+	for i, m := range ms {
+		if err := marshalInto(&res[i], m); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// marshalInto encodes m as OTLP/JSON into dst
+func marshalInto(dst *json.RawMessage, m proto.Message) error {
+	b, err := MarshalJSON(m)
+	*dst = b
+	return err
+}
+
+// apiFilter are the filters shared by the API endpoints
+type apiFilter struct {
+	since time.Time
+	attrs map[string]string // attr.<key> and service, matched against record and resource attributes
+}
+
+// parseAPIFilter parses since, as RFC 3339 or a duration ago, and attribute filters
+func parseAPIFilter(q url.Values) (apiFilter, error) {
+	f := apiFilter{attrs: map[string]string{}}
+	if s := q.Get("since"); s != "" {
+		if d, err := time.ParseDuration(s); err == nil {
+			f.since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			f.since = t
+		} else {
+			return f, fmt.Errorf("invalid since %q, expected a duration like 5m or an RFC 3339 time", s)
+		}
+	}
+	if s := q.Get("service"); s != "" {
+		f.attrs["service.name"] = s
+	}
+	for key, values := range q {
+		if k, ok := strings.CutPrefix(key, "attr."); ok {
+			f.attrs[k] = values[0]
+		}
+	}
+	return f, nil
+}
+
+// matches tells whether every filtered attribute is in one of attrs
+func (f apiFilter) matches(attrs ...[]*v1.KeyValue) bool {
+	for key, want := range f.attrs {
+		found := false
+		for _, kvs := range attrs {
+			for _, kv := range kvs {
+				if kv.Key == key && utils.AnyToString(kv.Value) == want {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// writePage writes the page of total items selected by the limit and offset query parameters
+func writePage(w http.ResponseWriter, req *http.Request, total int, item func(i int) (any, error)) {
+	q := req.URL.Query()
+	page := apiPage{Total: total, Limit: defaultAPILimit, Items: []any{}}
+	for _, p := range []struct {
+		name string
+		v    *int
+		max  int
+	}{{"limit", &page.Limit, maxAPILimit}, {"offset", &page.Offset, total}} {
+		if s := q.Get(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				apiError(w, http.StatusBadRequest, fmt.Errorf("invalid %s %q", p.name, s))
+				return
+			}
+			*p.v = min(n, p.max)
+		}
+	}
+
+	for i := page.Offset; i < total && i < page.Offset+page.Limit; i++ {
+		it, err := item(i)
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, err)
+			return
+		}
+		page.Items = append(page.Items, it)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// writeJSON encodes v before writing the status, so that failing to encode it is answered with 500
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		status = http.StatusInternalServerError
+		b, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(b, '\n'))
+}
+
+func apiError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
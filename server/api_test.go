@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func TestAPIDatapointsNonFinite(t *testing.T) {
+	Reset()
+	defer Reset()
+	consumeMetrics([]*metrics.ResourceMetrics{{ScopeMetrics: []*metrics.ScopeMetrics{{Metrics: []*metrics.Metric{{
+		Name: "latency",
+		Data: &metrics.Metric_Histogram{Histogram: &metrics.Histogram{
+			AggregationTemporality: metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			DataPoints:             []*metrics.HistogramDataPoint{{Count: 3, ExplicitBounds: []float64{1}, BucketCounts: []uint64{1, 2}}},
+		}},
+	}}}}}}, time.Now())
+	consumeMetrics(testGauge(1), time.Now())
+	for _, dps := range Storage.metrics {
+		if dps.Histograms == nil {
+			dps.Values[0] = math.NaN()
+		}
+	}
+
+	if n := len(GetMetrics()); n != 2 {
+		t.Fatalf("%d series, want 2", n)
+	}
+	for _, series := range GetMetrics() {
+		rec := httptest.NewRecorder()
+		apiHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/datapoints?series="+url.QueryEscape(series), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", series, rec.Code, rec.Body)
+		}
+		var res struct {
+			Values     []any `json:"values"`
+			Histograms []struct {
+				Min     *float64 `json:"min"`
+				Buckets []struct {
+					Lower any `json:"lower"`
+					Upper any `json:"upper"`
+				} `json:"buckets"`
+			} `json:"histograms"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatalf("%s: %v", series, err)
+		}
+		if strings.HasPrefix(series, "latency") {
+			h := res.Histograms[0]
+			if h.Min != nil || h.Buckets[0].Lower != "-Infinity" || h.Buckets[0].Upper != 1.0 || h.Buckets[1].Upper != "Infinity" {
+				t.Errorf("%s: %s", series, rec.Body)
+			}
+		} else if res.Values[0] != "NaN" {
+			t.Errorf("%s: %s", series, rec.Body)
+		}
+	}
+}
+
+func TestWriteJSONError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSON(rec, http.StatusOK, math.Inf(1))
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), `"error"`) {
+		t.Errorf("status %d: %s, want 500 and an error", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	logs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	metrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	traces "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// exporter sends export requests to an OTLP endpoint
+type exporter interface {
+	export(ctx context.Context, req proto.Message) error
+	close() error
+}
+
+// newExporter creates an exporter for target, which is either grpc://host:port,
+// grpcs://host:port for gRPC with TLS, or the base http(s):// URL of an OTLP/HTTP endpoint
+func newExporter(target string) (exporter, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: %w", target, err)
+	}
+	switch u.Scheme {
+	case "grpc", "grpcs":
+		creds := insecure.NewCredentials()
+		if u.Scheme == "grpcs" {
+			creds = credentials.NewTLS(&tls.Config{})
+		}
+		conn, err := grpc.NewClient(u.Host, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP endpoint %q: %w", target, err)
+		}
+		return &grpcExporter{conn: conn}, nil
+	case "http", "https":
+		return &httpExporter{base: strings.TrimSuffix(u.String(), "/")}, nil
+	default:
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: scheme must be one of grpc, grpcs, http or https", target)
+	}
+}
+
+type grpcExporter struct {
+	conn *grpc.ClientConn
+}
+
+func (e *grpcExporter) export(ctx context.Context, req proto.Message) (err error) {
+	switch req := req.(type) {
+	case *logs.ExportLogsServiceRequest:
+		_, err = logs.NewLogsServiceClient(e.conn).Export(ctx, req)
+	case *traces.ExportTraceServiceRequest:
+		_, err = traces.NewTraceServiceClient(e.conn).Export(ctx, req)
+	case *metrics.ExportMetricsServiceRequest:
+		_, err = metrics.NewMetricsServiceClient(e.conn).Export(ctx, req)
+	default:
+		err = fmt.Errorf("unsupported request %T", req)
+	}
+	return err
+}
+
+func (e *grpcExporter) close() error { return e.conn.Close() }
+
+type httpExporter struct {
+	base string
+}
+
+func (e *httpExporter) export(ctx context.Context, req proto.Message) error {
+	var path string
+	switch req.(type) {
+	case *logs.ExportLogsServiceRequest:
+		path = "/v1/logs"
+	case *traces.ExportTraceServiceRequest:
+		path = "/v1/traces"
+	case *metrics.ExportMetricsServiceRequest:
+		path = "/v1/metrics"
+	default:
+		return fmt.Errorf("unsupported request %T", req)
+	}
+
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.base+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Content-Type", "application/x-protobuf")
+
+	res, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode/100 != 2 {
+		return &httpStatusError{url: e.base + path, code: res.StatusCode, status: res.Status}
+	}
+	return nil
+}
+
+// httpStatusError is an unsuccessful response of an OTLP/HTTP endpoint
+type httpStatusError struct {
+	url    string
+	code   int
+	status string
+}
+
+func (e *httpStatusError) Error() string { return e.url + " responded with " + e.status }
+
+// retryable tells whether an export that failed with err may succeed if sent again, as in the OTLP specification.
+// Errors that aren't responses, like connection errors, are retryable.
+func retryable(err error) bool {
+	var herr *httpStatusError
+	if errors.As(err, &herr) {
+		switch herr.code {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted,
+			codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+func (e *httpExporter) close() error { return nil }
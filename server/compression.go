@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// maxBodySize is the maximum size of a decompressed HTTP request body, 0 for unlimited
+var maxBodySize int64
+
+// readBody reads the body of req, decompressing it according to its Content-Encoding
+func readBody(w http.ResponseWriter, req *http.Request) ([]byte, error) {
+	var body io.Reader = req.Body
+	if maxBodySize > 0 {
+		body = http.MaxBytesReader(w, req.Body, maxBodySize)
+	}
+
+	switch enc := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer zr.Close()
+		body = zr
+	case "deflate":
+		// deflate should be zlib wrapped, but raw deflate is common enough to accept too
+		raw, err := readLimited(body)
+		if err != nil {
+			return nil, err
+		}
+		if zr, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+			defer zr.Close()
+			body = zr
+		} else {
+			fr := flate.NewReader(bytes.NewReader(raw))
+			defer fr.Close()
+			body = fr
+		}
+	case "zstd":
+		zr, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid zstd body: %w", err)
+		}
+		defer zr.Close()
+		body = zr
+	case "snappy":
+		raw, err := readLimited(body)
+		if err != nil {
+			return nil, err
+		}
+		return decodeSnappy(raw)
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", enc)
+	}
+	return readLimited(body)
+}
+
+// decodeSnappy decodes a snappy block, as used by Prometheus remote write and Loki, without framing
+func decodeSnappy(b []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy body: %w", err)
+	}
+	if maxBodySize > 0 && int64(n) > maxBodySize {
+		return nil, errBodyTooLarge
+	}
+	out, err := snappy.Decode(nil, b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy body: %w", err)
+	}
+	return out, nil
+}
+
+// readLimited reads r, failing with errBodyTooLarge once more than maxBodySize bytes are read
+func readLimited(r io.Reader) ([]byte, error) {
+	if maxBodySize <= 0 {
+		return io.ReadAll(r)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, maxBodySize+1))
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) || int64(len(b)) > maxBodySize {
+		return nil, errBodyTooLarge
+	}
+	return b, err
+}
+
+// writeBody writes a response, compressed with gzip if the client accepts it
+func writeBody(w http.ResponseWriter, req *http.Request, b []byte) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(req.Header.Get("Accept-Encoding")) {
+		w.Write(b)
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	zw := gzip.NewWriter(w)
+	zw.Write(b)
+	zw.Close()
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, eg. "gzip, deflate;q=0.5"
+func acceptsGzip(header string) bool {
+	for enc := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(enc, ";")
+		if name = strings.TrimSpace(name); name != "gzip" && name != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
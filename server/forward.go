@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Forwarding retries requests that failed with a retryable error with an exponential backoff, before dropping them
+const (
+	forwardAttempts   = 5
+	forwardTimeout    = 10 * time.Second
+	forwardMinBackoff = 500 * time.Millisecond
+	forwardMaxBackoff = 30 * time.Second
+
+	defaultForwardQueue = 1000
+)
+
+// ForwardStats are running totals of requests forwarded upstream
+type ForwardStats struct {
+	Target string // empty if forwarding is disabled
+	Queued int    // waiting to be sent
+	Sent   int
+	// Failed requests were dropped after all attempts failed, Dropped ones because the queue was full
+	Failed      int
+	Dropped     int
+	Retries     int
+	LastError   string
+	LastErrorAt time.Time
+}
+
+// forwarder sends received requests to an upstream OTLP endpoint in the order they were received
+type forwarder struct {
+	exp   exporter
+	queue chan proto.Message
+
+	mu    sync.Mutex
+	stats ForwardStats
+}
+
+var forwarding *forwarder
+
+// startForwarding starts forwarding every received request to target until ctx is done,
+// see newExporter for the format of target
+func startForwarding(ctx context.Context, target string, queue int) error {
+	exp, err := newExporter(target)
+	if err != nil {
+		return err
+	}
+	if queue <= 0 {
+		queue = defaultForwardQueue
+	}
+	forwarding = &forwarder{exp: exp, queue: make(chan proto.Message, queue), stats: ForwardStats{Target: target}}
+	go forwarding.run(ctx)
+	return nil
+}
+
+// forward queues a request received over OTLP to be sent upstream, it is dropped if the queue is full
+func forward(req proto.Message) {
+	f := forwarding
+	if f == nil {
+		return
+	}
+	select {
+	case f.queue <- req:
+	default:
+		f.mu.Lock()
+		f.stats.Dropped++
+		f.mu.Unlock()
+	}
+}
+
+func (f *forwarder) run(ctx context.Context) {
+	defer f.exp.close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-f.queue:
+			f.send(ctx, req)
+		}
+	}
+}
+
+// send exports a request, retrying it until it succeeds, fails permanently, or runs out of attempts
+func (f *forwarder) send(ctx context.Context, req proto.Message) {
+	backoff := forwardMinBackoff
+	for attempt := 1; ; attempt++ {
+		tctx, cancel := context.WithTimeout(ctx, forwardTimeout)
+		err := f.exp.export(tctx, req)
+		cancel()
+
+		f.mu.Lock()
+		if err == nil {
+			f.stats.Sent++
+			f.mu.Unlock()
+			return
+		}
+		f.stats.LastError, f.stats.LastErrorAt = err.Error(), time.Now()
+		if attempt == forwardAttempts || !retryable(err) || ctx.Err() != nil {
+			f.stats.Failed++
+			f.mu.Unlock()
+			slog.WarnContext(ctx, "failed to forward request", "target", f.stats.Target, "attempts", attempt, "err", err)
+			return
+		}
+		f.stats.Retries++
+		f.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, forwardMaxBackoff)
+	}
+}
+
+// GetForwardStats returns the forwarding stats, with an empty Target if forwarding is disabled
+func GetForwardStats() ForwardStats {
+	f := forwarding
+	if f == nil {
+		return ForwardStats{}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.stats
+	s.Queued = len(f.queue)
+	return s
+}
+
+func resetForwardStats() {
+	f := forwarding
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stats = ForwardStats{Target: f.stats.Target}
+}
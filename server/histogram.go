@@ -0,0 +1,182 @@
+package server
+
+import (
+	"math"
+
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// Bucket counts the values in (Lower, Upper]
+type Bucket struct {
+	Lower float64
+	Upper float64
+	Count uint64
+}
+
+// Histogram is a histogram datapoint. Buckets of explicit and exponential
+// histograms are both converted to their boundaries, ordered from lowest.
+type Histogram struct {
+	Cumulative bool
+	Count      uint64
+	Sum        float64
+	Min        float64 // NaN when not reported
+	Max        float64 // NaN when not reported
+	Buckets    []Bucket
+}
+
+func newHistogram(cumulative bool, count uint64, sum, min, max *float64) *Histogram {
+	h := &Histogram{Cumulative: cumulative, Count: count, Min: math.NaN(), Max: math.NaN()}
+	if sum != nil {
+		h.Sum = *sum
+	}
+	if min != nil {
+		h.Min = *min
+	}
+	if max != nil {
+		h.Max = *max
+	}
+	return h
+}
+
+func explicitHistogram(dp *metrics.HistogramDataPoint, cumulative bool) *Histogram {
+	h := newHistogram(cumulative, dp.Count, dp.Sum, dp.Min, dp.Max)
+	if len(dp.BucketCounts) == 0 || len(dp.BucketCounts) != len(dp.ExplicitBounds)+1 {
+		return h
+	}
+	lower := math.Inf(-1)
+	for i, c := range dp.BucketCounts {
+		upper := math.Inf(1)
+		if i < len(dp.ExplicitBounds) {
+			upper = dp.ExplicitBounds[i]
+		}
+		h.Buckets = append(h.Buckets, Bucket{Lower: lower, Upper: upper, Count: c})
+		lower = upper
+	}
+	return h
+}
+
+func exponentialHistogram(dp *metrics.ExponentialHistogramDataPoint, cumulative bool) *Histogram {
+	h := newHistogram(cumulative, dp.Count, dp.Sum, dp.Min, dp.Max)
+	// bucket at index i covers (base^i, base^(i+1)] where base = 2^(2^-scale)
+	bound := func(i int) float64 { return math.Exp2(float64(i) * math.Exp2(-float64(dp.Scale))) }
+
+	if n := dp.Negative; n != nil {
+		for i := len(n.BucketCounts) - 1; i >= 0; i-- {
+			idx := int(n.Offset) + i
+			h.Buckets = append(h.Buckets, Bucket{Lower: -bound(idx + 1), Upper: -bound(idx), Count: n.BucketCounts[i]})
+		}
+	}
+	// the zero bucket is kept when empty, so it lines up with the one of other datapoints
+	h.Buckets = append(h.Buckets, Bucket{Lower: -dp.ZeroThreshold, Upper: dp.ZeroThreshold, Count: dp.ZeroCount})
+	if p := dp.Positive; p != nil {
+		for i, c := range p.BucketCounts {
+			idx := int(p.Offset) + i
+			h.Buckets = append(h.Buckets, Bucket{Lower: bound(idx), Upper: bound(idx + 1), Count: c})
+		}
+	}
+	return h
+}
+
+// Avg returns the mean of the recorded values
+func (h *Histogram) Avg() float64 {
+	if h.Count == 0 {
+		return math.NaN()
+	}
+	return h.Sum / float64(h.Count)
+}
+
+// Quantile estimates the q-quantile (0 <= q <= 1) by linear interpolation within buckets.
+// Infinite bucket boundaries are replaced with Min and Max when they are known.
+func (h *Histogram) Quantile(q float64) float64 {
+	var total uint64
+	for _, b := range h.Buckets {
+		total += b.Count
+	}
+	if total == 0 {
+		return math.NaN()
+	}
+
+	rank := q * float64(total)
+	var seen uint64
+	for i, b := range h.Buckets {
+		if b.Count == 0 || (float64(seen+b.Count) < rank && i < len(h.Buckets)-1) {
+			seen += b.Count
+			continue
+		}
+		lower, upper := b.Lower, b.Upper
+		if !math.IsNaN(h.Min) {
+			lower = max(lower, h.Min)
+		}
+		if !math.IsNaN(h.Max) {
+			upper = min(upper, h.Max)
+		}
+		if math.IsInf(lower, -1) {
+			lower = upper
+		}
+		if math.IsInf(upper, 1) {
+			upper = lower
+		}
+		return lower + (upper-lower)*max(0, min(1, (rank-float64(seen))/float64(b.Count)))
+	}
+	return math.NaN()
+}
+
+// Delta returns the difference between cumulative histogram h and an earlier prev,
+// or nil if they can't be subtracted, eg. after a reset or a change of buckets.
+// Buckets are matched by their boundaries, so buckets of exponential histograms line up
+// when their offsets change, with buckets missing from either side counted as empty.
+func (h *Histogram) Delta(prev *Histogram) *Histogram {
+	if prev == nil || h.Count < prev.Count {
+		return nil
+	}
+	d := &Histogram{
+		Count:   h.Count - prev.Count,
+		Sum:     h.Sum - prev.Sum,
+		Min:     math.NaN(),
+		Max:     math.NaN(),
+		Buckets: make([]Bucket, 0, len(h.Buckets)),
+	}
+	same := func(a, b Bucket) bool { return a.Lower == b.Lower && a.Upper == b.Upper }
+	j := 0
+	for _, b := range h.Buckets {
+		// buckets of prev below b must be empty, as their counts can't decrease
+		for j < len(prev.Buckets) && !same(prev.Buckets[j], b) && prev.Buckets[j].Upper <= b.Lower {
+			if prev.Buckets[j].Count > 0 {
+				return nil
+			}
+			j++
+		}
+		if j < len(prev.Buckets) && same(prev.Buckets[j], b) {
+			if prev.Buckets[j].Count > b.Count {
+				return nil
+			}
+			b.Count -= prev.Buckets[j].Count
+			j++
+		} else if j < len(prev.Buckets) && prev.Buckets[j].Lower < b.Upper {
+			// overlapping buckets of different boundaries
+			return nil
+		}
+		d.Buckets = append(d.Buckets, b)
+	}
+	for _, b := range prev.Buckets[j:] {
+		if b.Count > 0 {
+			return nil
+		}
+	}
+	return d
+}
+
+// HistogramIntervals returns the histograms of a series with cumulative ones
+// converted to the change since the previous datapoint where possible
+func (d *Datapoints) HistogramIntervals() []*Histogram {
+	res := make([]*Histogram, len(d.Histograms))
+	for i, h := range d.Histograms {
+		res[i] = h
+		if h.Cumulative && i > 0 {
+			if delta := h.Delta(d.Histograms[i-1]); delta != nil {
+				res[i] = delta
+			}
+		}
+	}
+	return res
+}
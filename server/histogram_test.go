@@ -0,0 +1,128 @@
+package server
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func counts(h *Histogram) []uint64 {
+	var res []uint64
+	for _, b := range h.Buckets {
+		res = append(res, b.Count)
+	}
+	return res
+}
+
+func TestExponentialHistogramDelta(t *testing.T) {
+	// buckets of scale 0 cover (2^i, 2^(i+1)]
+	dp := func(count, zero uint64, negOffset int32, neg []uint64, posOffset int32, pos []uint64) *Histogram {
+		return exponentialHistogram(&metrics.ExponentialHistogramDataPoint{
+			Count:     count,
+			ZeroCount: zero,
+			Negative:  &metrics.ExponentialHistogramDataPoint_Buckets{Offset: negOffset, BucketCounts: neg},
+			Positive:  &metrics.ExponentialHistogramDataPoint_Buckets{Offset: posOffset, BucketCounts: pos},
+		}, true)
+	}
+
+	tests := []struct {
+		name string
+		prev *Histogram
+		h    *Histogram
+		want []uint64 // nil when the histograms can't be subtracted
+	}{
+		{
+			name: "same buckets",
+			prev: dp(3, 0, 0, nil, 1, []uint64{1, 2}),
+			h:    dp(5, 0, 0, nil, 1, []uint64{2, 3}),
+			want: []uint64{0, 1, 1},
+		},
+		{
+			name: "zero count appears",
+			prev: dp(3, 0, 0, nil, 1, []uint64{1, 2}),
+			h:    dp(5, 2, 0, nil, 1, []uint64{1, 2}),
+			want: []uint64{2, 0, 0},
+		},
+		{
+			name: "positive offset shifts down",
+			prev: dp(3, 0, 0, nil, 2, []uint64{1, 2}),
+			h:    dp(6, 0, 0, nil, 1, []uint64{1, 1, 3}),
+			want: []uint64{0, 1, 0, 1},
+		},
+		{
+			name: "positive buckets grow up",
+			prev: dp(3, 0, 0, nil, 1, []uint64{1, 2}),
+			h:    dp(4, 0, 0, nil, 1, []uint64{1, 2, 1}),
+			want: []uint64{0, 0, 0, 1},
+		},
+		{
+			name: "negative offset shifts",
+			prev: dp(2, 1, 1, []uint64{1}, 0, nil),
+			h:    dp(4, 1, 0, []uint64{1, 2}, 0, nil),
+			want: []uint64{1, 1, 0},
+		},
+		{
+			name: "empty bucket dropped",
+			prev: dp(2, 0, 0, nil, 0, []uint64{0, 2}),
+			h:    dp(3, 0, 0, nil, 1, []uint64{3}),
+			want: []uint64{0, 1},
+		},
+		{
+			name: "count decreased",
+			prev: dp(3, 0, 0, nil, 1, []uint64{1, 2}),
+			h:    dp(3, 0, 0, nil, 2, []uint64{3}),
+			want: nil,
+		},
+		{
+			name: "reset",
+			prev: dp(3, 0, 0, nil, 1, []uint64{1, 2}),
+			h:    dp(1, 0, 0, nil, 1, []uint64{1}),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.h.Delta(tt.prev)
+			if tt.want == nil {
+				if d != nil {
+					t.Fatalf("Delta() = %v, want nil", d.Buckets)
+				}
+				return
+			}
+			if d == nil {
+				t.Fatal("Delta() = nil")
+			}
+			if got := counts(d); !slices.Equal(got, tt.want) {
+				t.Errorf("Delta() counts %v, want %v", got, tt.want)
+			}
+			if d.Count != tt.h.Count-tt.prev.Count {
+				t.Errorf("Delta() count %d, want %d", d.Count, tt.h.Count-tt.prev.Count)
+			}
+		})
+	}
+}
+
+func TestExplicitHistogramDelta(t *testing.T) {
+	dp := func(bounds []float64, buckets ...uint64) *Histogram {
+		var count uint64
+		for _, c := range buckets {
+			count += c
+		}
+		return explicitHistogram(&metrics.HistogramDataPoint{Count: count, ExplicitBounds: bounds, BucketCounts: buckets}, true)
+	}
+	prev := dp([]float64{1, 10}, 1, 2, 0)
+	if d := dp([]float64{1, 10}, 2, 2, 1).Delta(prev); d == nil || !slices.Equal(counts(d), []uint64{1, 0, 1}) {
+		t.Errorf("Delta() = %v, want counts 1, 0 and 1", d)
+	}
+	if d := dp([]float64{1, 5, 10}, 1, 2, 0, 0).Delta(prev); d != nil {
+		t.Errorf("Delta() of changed bounds = %v, want nil", d.Buckets)
+	}
+	if q := dp([]float64{1, 10}, 0, 4, 0).Quantile(0.5); q != 5.5 {
+		t.Errorf("Quantile(0.5) = %v, want 5.5", q)
+	}
+	if q := dp(nil).Quantile(0.5); !math.IsNaN(q) {
+		t.Errorf("Quantile() of an empty histogram = %v, want NaN", q)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	logs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	metrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	traces "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Import reads OTLP/JSON export requests, eg. as written by the collector's file exporter, or traces
+// downloaded from the Jaeger UI, and stores them as if they were just received, without persisting them.
+// It returns the number of imported requests.
+func Import(r io.Reader) (int, error) {
+	dec := json.NewDecoder(r)
+	n := 0
+	for i := 1; ; i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, fmt.Errorf("failed to read request %d: %w", i, err)
+		}
+
+		req, err := decodeRequest(raw)
+		if err != nil {
+			slog.Warn("skipping request that failed to import", "request", i, "err", err)
+			continue
+		}
+		// imported files can be imported again, so they aren't persisted
+		ingest(time.Now().UTC(), req)
+		n++
+	}
+}
+
+// decodeRequest decodes an OTLP/JSON export request of any signal, or Jaeger UI JSON
+func decodeRequest(raw json.RawMessage) (proto.Message, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, err
+	}
+
+	var req proto.Message
+	switch {
+	case keys["resourceLogs"] != nil || keys["resource_logs"] != nil:
+		req = &logs.ExportLogsServiceRequest{}
+	case keys["resourceSpans"] != nil || keys["resource_spans"] != nil:
+		req = &traces.ExportTraceServiceRequest{}
+	case keys["resourceMetrics"] != nil || keys["resource_metrics"] != nil:
+		req = &metrics.ExportMetricsServiceRequest{}
+	case keys["data"] != nil:
+		return decodeJaegerJSON(raw)
+	default:
+		return nil, fmt.Errorf("not an OTLP export request or Jaeger traces")
+	}
+	return req, unmarshalJSON(raw, req)
+}
@@ -0,0 +1,467 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	coltraces "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	traces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// TransportJaeger is the Jaeger collector HTTP API, in Thrift
+const TransportJaeger = "http/jaeger"
+
+// jaegerSpan is a span of the Jaeger model, decoded from Thrift or the JSON of the Jaeger UI
+type jaegerSpan struct {
+	traceID, spanID, parentID []byte
+	name                      string
+	refs                      []jaegerRef
+	start, duration           uint64 // microseconds
+	tags                      []*v1.KeyValue
+	logs                      []jaegerLog
+	process                   *jaegerProcess
+}
+
+type jaegerRef struct {
+	followsFrom     bool
+	traceID, spanID []byte
+}
+
+type jaegerLog struct {
+	timestamp uint64 // microseconds
+	fields    []*v1.KeyValue
+}
+
+type jaegerProcess struct {
+	service string
+	tags    []*v1.KeyValue
+}
+
+// handleJaeger receives a Thrift encoded batch POSTed to the Jaeger collector API, /api/traces
+func handleJaeger(w http.ResponseWriter, req *http.Request) {
+	handleHTTP(w, req, TransportJaeger, http.StatusAccepted, "X-Rejected-Spans", func(body []byte) (proto.Message, error) {
+		switch ct, _, _ := strings.Cut(req.Header.Get("Content-Type"), ";"); ct {
+		case "application/x-thrift", "application/vnd.apache.thrift.binary":
+		default:
+			return nil, unsupportedError(fmt.Sprintf("Unsupported Content-Type %q, only Thrift is accepted", ct))
+		}
+		spans, err := decodeJaegerThrift(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Jaeger batch: %w", err)
+		}
+		return jaegerToOTLP(spans), nil
+	})
+}
+
+// jaegerToOTLP translates Jaeger spans the way the collector's Jaeger receiver does: the process
+// becomes the resource, references become the parent span ID and links, and logs become events
+func jaegerToOTLP(spans []jaegerSpan) *coltraces.ExportTraceServiceRequest {
+	req := &coltraces.ExportTraceServiceRequest{}
+	byProcess := map[*jaegerProcess]*traces.ResourceSpans{}
+	byScope := map[*traces.ResourceSpans]map[[2]string]*traces.ScopeSpans{}
+
+	for _, js := range spans {
+		rs, ok := byProcess[js.process]
+		if !ok {
+			res := &resource.Resource{}
+			if js.process != nil {
+				res.Attributes = append([]*v1.KeyValue{stringAttr("service.name", js.process.service)}, js.process.tags...)
+			}
+			rs = &traces.ResourceSpans{Resource: res}
+			byProcess[js.process] = rs
+			byScope[rs] = map[[2]string]*traces.ScopeSpans{}
+			req.ResourceSpans = append(req.ResourceSpans, rs)
+		}
+
+		span, scope := jaegerSpanToOTLP(js)
+		ss, ok := byScope[rs][scope]
+		if !ok {
+			ss = &traces.ScopeSpans{Scope: &v1.InstrumentationScope{Name: scope[0], Version: scope[1]}}
+			byScope[rs][scope] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+		ss.Spans = append(ss.Spans, span)
+	}
+	return req
+}
+
+func jaegerSpanToOTLP(js jaegerSpan) (*traces.Span, [2]string) {
+	span := &traces.Span{
+		TraceId:           js.traceID,
+		SpanId:            js.spanID,
+		ParentSpanId:      js.parentID,
+		Name:              js.name,
+		StartTimeUnixNano: js.start * 1000,
+		EndTimeUnixNano:   (js.start + js.duration) * 1000,
+		Status:            &traces.Status{},
+	}
+
+	// the first reference to a parent in the same trace is the parent, others are links
+	for _, ref := range js.refs {
+		if len(span.ParentSpanId) == 0 && !ref.followsFrom && string(ref.traceID) == string(js.traceID) {
+			span.ParentSpanId = ref.spanID
+			continue
+		}
+		if string(ref.spanID) == string(span.ParentSpanId) && string(ref.traceID) == string(js.traceID) {
+			continue
+		}
+		refType := "child_of"
+		if ref.followsFrom {
+			refType = "follows_from"
+		}
+		span.Links = append(span.Links, &traces.Span_Link{
+			TraceId:    ref.traceID,
+			SpanId:     ref.spanID,
+			Attributes: []*v1.KeyValue{stringAttr("opentracing.ref_type", refType)},
+		})
+	}
+
+	var scope [2]string
+	for _, kv := range js.tags {
+		switch kv.Key {
+		case "span.kind":
+			switch kv.Value.GetStringValue() {
+			case "client":
+				span.Kind = traces.Span_SPAN_KIND_CLIENT
+			case "server":
+				span.Kind = traces.Span_SPAN_KIND_SERVER
+			case "producer":
+				span.Kind = traces.Span_SPAN_KIND_PRODUCER
+			case "consumer":
+				span.Kind = traces.Span_SPAN_KIND_CONSUMER
+			case "internal":
+				span.Kind = traces.Span_SPAN_KIND_INTERNAL
+			default:
+				span.Attributes = append(span.Attributes, kv)
+			}
+		case "otel.status_code":
+			switch strings.ToUpper(kv.Value.GetStringValue()) {
+			case "OK":
+				span.Status.Code = traces.Status_STATUS_CODE_OK
+			case "ERROR":
+				span.Status.Code = traces.Status_STATUS_CODE_ERROR
+			}
+		case "otel.status_description":
+			span.Status.Message = kv.Value.GetStringValue()
+		case "error":
+			if kv.Value.GetBoolValue() || kv.Value.GetStringValue() == "true" {
+				span.Status.Code = traces.Status_STATUS_CODE_ERROR
+			}
+		case "otel.scope.name", "otel.library.name":
+			scope[0] = kv.Value.GetStringValue()
+		case "otel.scope.version", "otel.library.version":
+			scope[1] = kv.Value.GetStringValue()
+		case "w3c.tracestate":
+			span.TraceState = kv.Value.GetStringValue()
+		default:
+			span.Attributes = append(span.Attributes, kv)
+		}
+	}
+
+	for _, l := range js.logs {
+		e := &traces.Span_Event{TimeUnixNano: l.timestamp * 1000}
+		for _, kv := range l.fields {
+			if kv.Key == "event" && e.Name == "" {
+				e.Name = kv.Value.GetStringValue()
+				continue
+			}
+			e.Attributes = append(e.Attributes, kv)
+		}
+		span.Events = append(span.Events, e)
+	}
+	return span, scope
+}
+
+// jaegerID encodes the two 64-bit halves of a Jaeger ID as bytes, big endian
+func jaegerID(high, low int64) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(high))
+	binary.BigEndian.PutUint64(b[8:], uint64(low))
+	return b
+}
+
+func jaegerSpanID(id int64) []byte {
+	if id == 0 {
+		return nil
+	}
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+// decodeJaegerThrift decodes a Batch struct of jaeger.thrift
+func decodeJaegerThrift(b []byte) ([]jaegerSpan, error) {
+	r := &thriftReader{b: b}
+	process := &jaegerProcess{}
+	var spans []jaegerSpan
+	r.structFields(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftStruct:
+			r.structFields(func(id int16, typ byte) {
+				switch {
+				case id == 1 && typ == thriftString:
+					process.service = r.string()
+				case id == 2 && typ == thriftList:
+					process.tags = readJaegerTags(r)
+				default:
+					r.skip(typ)
+				}
+			})
+		case id == 2 && typ == thriftList:
+			r.list(func(typ byte) {
+				if typ != thriftStruct {
+					r.skip(typ)
+					return
+				}
+				spans = append(spans, readJaegerSpan(r))
+			})
+		default:
+			r.skip(typ)
+		}
+	})
+	if r.err != nil {
+		return nil, r.err
+	}
+	for i := range spans {
+		spans[i].process = process
+	}
+	return spans, nil
+}
+
+func readJaegerSpan(r *thriftReader) jaegerSpan {
+	var (
+		s                 jaegerSpan
+		traceLow, traceHi int64
+	)
+	r.structFields(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftI64:
+			traceLow = r.i64()
+		case id == 2 && typ == thriftI64:
+			traceHi = r.i64()
+		case id == 3 && typ == thriftI64:
+			s.spanID = jaegerSpanID(r.i64())
+		case id == 4 && typ == thriftI64:
+			s.parentID = jaegerSpanID(r.i64())
+		case id == 5 && typ == thriftString:
+			s.name = r.string()
+		case id == 6 && typ == thriftList:
+			r.list(func(typ byte) {
+				if typ != thriftStruct {
+					r.skip(typ)
+					return
+				}
+				var (
+					ref     jaegerRef
+					low, hi int64
+					spanID  int64
+					refType int32
+				)
+				r.structFields(func(id int16, typ byte) {
+					switch {
+					case id == 1 && typ == thriftI32:
+						refType = r.i32()
+					case id == 2 && typ == thriftI64:
+						low = r.i64()
+					case id == 3 && typ == thriftI64:
+						hi = r.i64()
+					case id == 4 && typ == thriftI64:
+						spanID = r.i64()
+					default:
+						r.skip(typ)
+					}
+				})
+				ref.followsFrom = refType == 1
+				ref.traceID = jaegerID(hi, low)
+				ref.spanID = jaegerSpanID(spanID)
+				s.refs = append(s.refs, ref)
+			})
+		case id == 8 && typ == thriftI64:
+			s.start = uint64(r.i64())
+		case id == 9 && typ == thriftI64:
+			s.duration = uint64(r.i64())
+		case id == 10 && typ == thriftList:
+			s.tags = readJaegerTags(r)
+		case id == 11 && typ == thriftList:
+			r.list(func(typ byte) {
+				if typ != thriftStruct {
+					r.skip(typ)
+					return
+				}
+				var l jaegerLog
+				r.structFields(func(id int16, typ byte) {
+					switch {
+					case id == 1 && typ == thriftI64:
+						l.timestamp = uint64(r.i64())
+					case id == 2 && typ == thriftList:
+						l.fields = readJaegerTags(r)
+					default:
+						r.skip(typ)
+					}
+				})
+				s.logs = append(s.logs, l)
+			})
+		default:
+			r.skip(typ)
+		}
+	})
+	s.traceID = jaegerID(traceHi, traceLow)
+	return s
+}
+
+func readJaegerTags(r *thriftReader) []*v1.KeyValue {
+	var tags []*v1.KeyValue
+	r.list(func(typ byte) {
+		if typ != thriftStruct {
+			r.skip(typ)
+			return
+		}
+		var (
+			key   string
+			vType int32
+			value = map[int32]*v1.AnyValue{}
+		)
+		r.structFields(func(id int16, typ byte) {
+			switch {
+			case id == 1 && typ == thriftString:
+				key = r.string()
+			case id == 2 && typ == thriftI32:
+				vType = r.i32()
+			case id == 3 && typ == thriftString:
+				value[0] = &v1.AnyValue{Value: &v1.AnyValue_StringValue{StringValue: r.string()}}
+			case id == 4 && typ == thriftDouble:
+				value[1] = &v1.AnyValue{Value: &v1.AnyValue_DoubleValue{DoubleValue: r.double()}}
+			case id == 5 && typ == thriftBool:
+				value[2] = &v1.AnyValue{Value: &v1.AnyValue_BoolValue{BoolValue: r.bool()}}
+			case id == 6 && typ == thriftI64:
+				value[3] = &v1.AnyValue{Value: &v1.AnyValue_IntValue{IntValue: r.i64()}}
+			case id == 7 && typ == thriftString:
+				value[4] = &v1.AnyValue{Value: &v1.AnyValue_BytesValue{BytesValue: r.binary()}}
+			default:
+				r.skip(typ)
+			}
+		})
+		v := value[vType]
+		if v == nil {
+			v = &v1.AnyValue{}
+		}
+		tags = append(tags, &v1.KeyValue{Key: key, Value: v})
+	})
+	return tags
+}
+
+// jaegerJSON is the JSON the Jaeger UI and query API return, eg. with "Download JSON"
+type jaegerJSON struct {
+	Data []struct {
+		Spans []struct {
+			TraceID       string `json:"traceID"`
+			SpanID        string `json:"spanID"`
+			OperationName string `json:"operationName"`
+			References    []struct {
+				RefType string `json:"refType"`
+				TraceID string `json:"traceID"`
+				SpanID  string `json:"spanID"`
+			} `json:"references"`
+			StartTime uint64          `json:"startTime"`
+			Duration  uint64          `json:"duration"`
+			Tags      []jaegerJSONTag `json:"tags"`
+			Logs      []struct {
+				Timestamp uint64          `json:"timestamp"`
+				Fields    []jaegerJSONTag `json:"fields"`
+			} `json:"logs"`
+			ProcessID string `json:"processID"`
+		} `json:"spans"`
+		Processes map[string]struct {
+			ServiceName string          `json:"serviceName"`
+			Tags        []jaegerJSONTag `json:"tags"`
+		} `json:"processes"`
+	} `json:"data"`
+}
+
+type jaegerJSONTag struct {
+	Key   string          `json:"key"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// decodeJaegerJSON decodes the JSON of the Jaeger UI into an export request
+func decodeJaegerJSON(raw []byte) (*coltraces.ExportTraceServiceRequest, error) {
+	var doc jaegerJSON
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	var spans []jaegerSpan
+	for _, t := range doc.Data {
+		processes := map[string]*jaegerProcess{}
+		for id, p := range t.Processes {
+			processes[id] = &jaegerProcess{service: p.ServiceName, tags: jaegerJSONTags(p.Tags)}
+		}
+		for _, s := range t.Spans {
+			js := jaegerSpan{
+				traceID:  hexID(s.TraceID, 16),
+				spanID:   hexID(s.SpanID, 8),
+				name:     s.OperationName,
+				start:    s.StartTime,
+				duration: s.Duration,
+				tags:     jaegerJSONTags(s.Tags),
+				process:  processes[s.ProcessID],
+			}
+			for _, ref := range s.References {
+				js.refs = append(js.refs, jaegerRef{
+					followsFrom: ref.RefType == "FOLLOWS_FROM",
+					traceID:     hexID(ref.TraceID, 16),
+					spanID:      hexID(ref.SpanID, 8),
+				})
+			}
+			for _, l := range s.Logs {
+				js.logs = append(js.logs, jaegerLog{timestamp: l.Timestamp, fields: jaegerJSONTags(l.Fields)})
+			}
+			spans = append(spans, js)
+		}
+	}
+	return jaegerToOTLP(spans), nil
+}
+
+func jaegerJSONTags(tags []jaegerJSONTag) []*v1.KeyValue {
+	kvs := make([]*v1.KeyValue, 0, len(tags))
+	for _, t := range tags {
+		v := &v1.AnyValue{}
+		var s string
+		json.Unmarshal(t.Value, &s)
+		switch t.Type {
+		case "bool":
+			var b bool
+			json.Unmarshal(t.Value, &b)
+			v.Value = &v1.AnyValue_BoolValue{BoolValue: b}
+		case "int64":
+			var n json.Number
+			json.Unmarshal(t.Value, &n)
+			i, _ := strconv.ParseInt(n.String(), 10, 64)
+			v.Value = &v1.AnyValue_IntValue{IntValue: i}
+		case "float64":
+			var f float64
+			json.Unmarshal(t.Value, &f)
+			v.Value = &v1.AnyValue_DoubleValue{DoubleValue: f}
+		case "binary":
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				b = []byte(s)
+			}
+			v.Value = &v1.AnyValue_BytesValue{BytesValue: b}
+		default:
+			if s == "" && len(t.Value) > 0 && t.Value[0] != '"' {
+				s = string(t.Value)
+			}
+			v.Value = &v1.AnyValue_StringValue{StringValue: s}
+		}
+		kvs = append(kvs, &v1.KeyValue{Key: t.Key, Value: v})
+	}
+	return kvs
+}
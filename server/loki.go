@@ -0,0 +1,213 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// TransportLoki is the Loki push API, in JSON or snappy compressed protobuf
+const TransportLoki = "http/loki"
+
+// lokiStream is a stream of the push API, with entries timestamped in nanoseconds
+type lokiStream struct {
+	labels  []promLabel
+	entries []lokiEntry
+}
+
+type lokiEntry struct {
+	timestamp int64
+	line      string
+	metadata  []promLabel // structured metadata
+}
+
+// handleLoki receives streams POSTed to the Loki push API, /loki/api/v1/push
+func handleLoki(w http.ResponseWriter, req *http.Request) {
+	isJSON := strings.HasPrefix(req.Header.Get("Content-Type"), "application/json")
+	if !isJSON && req.Header.Get("Content-Encoding") == "" {
+		// protobuf is always snappy compressed, without saying so
+		req.Header.Set("Content-Encoding", "snappy")
+	}
+	handleHTTP(w, req, TransportLoki, http.StatusNoContent, "X-Rejected-Logs", func(body []byte) (proto.Message, error) {
+		var streams []lokiStream
+		var err error
+		if isJSON {
+			streams, err = decodeLokiJSON(body)
+		} else {
+			streams, err = decodeLokiProto(body)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid push request: %w", err)
+		}
+		return lokiToOTLP(streams), nil
+	})
+}
+
+// lokiToOTLP translates streams to logs with their labels as resource attributes, and service_name or job
+// as service.name. Lines are parsed like tailed ones, and structured metadata becomes attributes.
+func lokiToOTLP(streams []lokiStream) *collogs.ExportLogsServiceRequest {
+	req := &collogs.ExportLogsServiceRequest{}
+	scope := &v1.InstrumentationScope{Name: "otelui/loki"}
+	for _, s := range streams {
+		res := &resource.Resource{Attributes: promAttributes(s.labels, "")}
+		service := ""
+		for _, l := range s.labels {
+			if l.name == "service_name" || (l.name == "job" && service == "") {
+				service = l.value
+			}
+		}
+		if service != "" {
+			res.Attributes = append([]*v1.KeyValue{stringAttr("service.name", service)}, res.Attributes...)
+		}
+
+		sl := &logs.ScopeLogs{Scope: scope}
+		for _, e := range s.entries {
+			l := parseLine(e.line)
+			if l == nil {
+				l = &logs.LogRecord{Body: stringValueOf(e.line)}
+			}
+			l.TimeUnixNano = uint64(e.timestamp)
+			l.Attributes = append(l.Attributes, promAttributes(e.metadata, "")...)
+			sl.LogRecords = append(sl.LogRecords, l)
+		}
+		req.ResourceLogs = append(req.ResourceLogs, &logs.ResourceLogs{Resource: res, ScopeLogs: []*logs.ScopeLogs{sl}})
+	}
+	return req
+}
+
+// decodeLokiJSON decodes {"streams": [{"stream": {labels}, "values": [["<ns>", "line", {metadata}]]}]}
+func decodeLokiJSON(b []byte) ([]lokiStream, error) {
+	var push struct {
+		Streams []struct {
+			Stream map[string]string   `json:"stream"`
+			Values [][]json.RawMessage `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(b, &push); err != nil {
+		return nil, err
+	}
+
+	streams := make([]lokiStream, 0, len(push.Streams))
+	for _, ps := range push.Streams {
+		var s lokiStream
+		for name, value := range ps.Stream {
+			s.labels = append(s.labels, promLabel{name, value})
+		}
+		sortLabels(s.labels)
+		for _, v := range ps.Values {
+			if len(v) < 2 {
+				return nil, fmt.Errorf("entry of stream %v has no line", ps.Stream)
+			}
+			var (
+				ts       string
+				e        lokiEntry
+				metadata map[string]string
+			)
+			if err := json.Unmarshal(v[0], &ts); err != nil {
+				return nil, fmt.Errorf("invalid timestamp: %w", err)
+			}
+			t, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp: %w", err)
+			}
+			e.timestamp = t
+			if err := json.Unmarshal(v[1], &e.line); err != nil {
+				return nil, fmt.Errorf("invalid line: %w", err)
+			}
+			if len(v) > 2 {
+				if err := json.Unmarshal(v[2], &metadata); err != nil {
+					return nil, fmt.Errorf("invalid structured metadata: %w", err)
+				}
+				for name, value := range metadata {
+					e.metadata = append(e.metadata, promLabel{name, value})
+				}
+				sortLabels(e.metadata)
+			}
+			s.entries = append(s.entries, e)
+		}
+		streams = append(streams, s)
+	}
+	return streams, nil
+}
+
+// decodeLokiProto decodes a PushRequest of Loki's push.proto
+func decodeLokiProto(b []byte) ([]lokiStream, error) {
+	var streams []lokiStream
+	err := walkProto(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		var s lokiStream
+		err := walkProto(v, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+			switch num {
+			case 1:
+				labels, _, err := parsePromLabels(string(v))
+				if err != nil {
+					return fmt.Errorf("invalid stream labels %q: %w", v, err)
+				}
+				s.labels = labels
+			case 2:
+				e, err := decodeLokiEntry(v)
+				if err != nil {
+					return err
+				}
+				s.entries = append(s.entries, e)
+			}
+			return nil
+		})
+		streams = append(streams, s)
+		return err
+	})
+	return streams, err
+}
+
+func decodeLokiEntry(b []byte) (e lokiEntry, err error) {
+	err = walkProto(b, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+		switch num {
+		case 1:
+			// google.protobuf.Timestamp
+			return walkProto(v, func(num protowire.Number, _ protowire.Type, _ []byte, n uint64) error {
+				switch num {
+				case 1:
+					e.timestamp += int64(n) * int64(time.Second)
+				case 2:
+					e.timestamp += int64(int32(n))
+				}
+				return nil
+			})
+		case 2:
+			e.line = string(v)
+		case 3:
+			var l promLabel
+			if err := walkProto(v, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+				switch num {
+				case 1:
+					l.name = string(v)
+				case 2:
+					l.value = string(v)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			e.metadata = append(e.metadata, l)
+		}
+		return nil
+	})
+	return e, err
+}
+
+func sortLabels(labels []promLabel) {
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+}
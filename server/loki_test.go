@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+func TestDecodeLokiJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []lokiStream
+		err  string
+	}{
+		{
+			name: "streams",
+			body: `{"streams": [
+				{"stream": {"job": "api", "env": "prod"}, "values": [["1700000000000000000", "started"], ["1700000001000000000", "ready", {"user": "42", "a": "b"}]]},
+				{"stream": {}, "values": []}
+			]}`,
+			want: []lokiStream{
+				{labels: []promLabel{{"env", "prod"}, {"job", "api"}}, entries: []lokiEntry{
+					{timestamp: 1700000000000000000, line: "started"},
+					{timestamp: 1700000001000000000, line: "ready", metadata: []promLabel{{"a", "b"}, {"user", "42"}}},
+				}},
+				{entries: nil},
+			},
+		},
+		{name: "no streams", body: `{}`, want: []lokiStream{}},
+		{name: "invalid JSON", body: `{"streams": [`, err: "unexpected end of JSON input"},
+		{name: "missing line", body: `{"streams": [{"stream": {}, "values": [["1"]]}]}`, err: "has no line"},
+		{name: "numeric timestamp", body: `{"streams": [{"stream": {}, "values": [[1, "x"]]}]}`, err: "invalid timestamp"},
+		{name: "invalid timestamp", body: `{"streams": [{"stream": {}, "values": [["soon", "x"]]}]}`, err: "invalid timestamp"},
+		{name: "invalid line", body: `{"streams": [{"stream": {}, "values": [["1", 2]]}]}`, err: "invalid line"},
+		{name: "invalid metadata", body: `{"streams": [{"stream": {}, "values": [["1", "x", []]]}]}`, err: "invalid structured metadata"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeLokiJSON([]byte(tt.body))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("decodeLokiJSON() error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeLokiJSON() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeLokiProto(t *testing.T) {
+	timestamp := protoVarint(protoVarint(nil, 1, 1700000000), 2, 500)
+	entry := protoBytes(nil, 1, timestamp)
+	entry = protoBytes(entry, 2, []byte("GET /cart 200"))
+	entry = protoBytes(entry, 3, protoBytes(protoBytes(nil, 1, []byte("trace_id")), 2, []byte("abc")))
+	stream := protoBytes(nil, 1, []byte(`{job="api", env="prod"}`))
+	stream = protoBytes(stream, 2, entry)
+	push := protoBytes(nil, 1, stream)
+
+	got, err := decodeLokiProto(push)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []lokiStream{{
+		labels: []promLabel{{"job", "api"}, {"env", "prod"}},
+		entries: []lokiEntry{{
+			timestamp: 1700000000000000500, line: "GET /cart 200", metadata: []promLabel{{"trace_id", "abc"}},
+		}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeLokiProto() = %+v, want %+v", got, want)
+	}
+
+	invalid := protoBytes(nil, 1, protoBytes(nil, 1, []byte(`{job=api}`)))
+	if _, err := decodeLokiProto(invalid); err == nil || !strings.Contains(err.Error(), "invalid stream labels") {
+		t.Errorf("decodeLokiProto() error %v, want invalid stream labels", err)
+	}
+	if _, err := decodeLokiProto(push[:len(push)-1]); err == nil {
+		t.Error("decodeLokiProto() of a truncated request succeeded")
+	}
+}
+
+func TestLokiToOTLP(t *testing.T) {
+	streams := []lokiStream{
+		{labels: []promLabel{{"job", "api"}, {"service_name", "checkout"}}, entries: []lokiEntry{
+			{timestamp: 1700000000000000000, line: `{"level":"warn","msg":"slow","user":"42"}`, metadata: []promLabel{{"pod", "a"}}},
+			{timestamp: 1700000001000000000, line: "ERROR disk full"},
+		}},
+		{labels: []promLabel{{"job", "worker"}}, entries: []lokiEntry{{timestamp: 1, line: " "}}},
+	}
+	req := lokiToOTLP(streams)
+	if len(req.ResourceLogs) != 2 {
+		t.Fatalf("%d resources, want one per stream", len(req.ResourceLogs))
+	}
+
+	res := attrStrings(req.ResourceLogs[0].Resource.Attributes)
+	if want := map[string]string{"service.name": "checkout", "job": "api", "service_name": "checkout"}; !reflect.DeepEqual(res, want) {
+		t.Errorf("resource %v, want %v", res, want)
+	}
+	if res := attrStrings(req.ResourceLogs[1].Resource.Attributes); res["service.name"] != "worker" {
+		t.Errorf("service.name %q, want the job", res["service.name"])
+	}
+
+	tests := []struct {
+		log      *logs.LogRecord
+		time     uint64
+		severity logs.SeverityNumber
+		body     string
+		attrs    map[string]string
+	}{
+		{req.ResourceLogs[0].ScopeLogs[0].LogRecords[0], 1700000000000000000, logs.SeverityNumber_SEVERITY_NUMBER_WARN, "slow",
+			map[string]string{"user": "42", "pod": "a"}},
+		{req.ResourceLogs[0].ScopeLogs[0].LogRecords[1], 1700000001000000000, logs.SeverityNumber_SEVERITY_NUMBER_ERROR, "ERROR disk full",
+			map[string]string{}},
+		// blank lines are kept as is
+		{req.ResourceLogs[1].ScopeLogs[0].LogRecords[0], 1, 0, " ", map[string]string{}},
+	}
+	for i, tt := range tests {
+		l := tt.log
+		if l.TimeUnixNano != tt.time || l.SeverityNumber != tt.severity || l.Body.GetStringValue() != tt.body {
+			t.Errorf("log %d: time %d, severity %v and body %q, want %d, %v and %q", i, l.TimeUnixNano, l.SeverityNumber, l.Body.GetStringValue(), tt.time, tt.severity, tt.body)
+		}
+		if attrs := attrStrings(l.Attributes); !reflect.DeepEqual(attrs, tt.attrs) {
+			t.Errorf("log %d: attributes %v, want %v", i, attrs, tt.attrs)
+		}
+	}
+}
+
+func TestHandleLoki(t *testing.T) {
+	setupStorage(Limits{})
+	push := protoBytes(nil, 1, protoBytes(protoBytes(nil, 1, []byte(`{job="api"}`)), 2, protoBytes(protoBytes(nil, 1, nil), 2, []byte("GET /cart"))))
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		status      int
+	}{
+		{"JSON", "application/json", []byte(`{"streams": [{"stream": {"job": "api"}, "values": [["1", "started"]]}]}`), http.StatusNoContent},
+		{"protobuf", "application/x-protobuf", snappy.Encode(nil, push), http.StatusNoContent},
+		{"uncompressed protobuf", "application/x-protobuf", push, http.StatusBadRequest},
+		{"invalid JSON", "application/json; charset=utf-8", []byte(`{"streams": 1}`), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", bytes.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		handleLoki(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+	if n := len(Storage.logs); n != 2 {
+		t.Errorf("%d logs received, want 2", n)
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// idFields are the OTLP fields that are hex encoded in OTLP/JSON, unlike the base64 protojson uses for bytes
+var idFields = map[string]bool{
+	"traceId": true, "trace_id": true,
+	"spanId": true, "span_id": true,
+	"parentSpanId": true, "parent_span_id": true,
+}
+
+// unmarshalJSON decodes an OTLP/JSON message
+func unmarshalJSON(b []byte, m proto.Message) error {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	convertIDs(v, func(s string) string {
+		// IDs that aren't valid hex are left for protojson to decode as base64
+		if id, err := hex.DecodeString(s); err == nil {
+			return base64.StdEncoding.EncodeToString(id)
+		}
+		return s
+	})
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(b, m)
+}
+
+// MarshalJSON encodes m as OTLP/JSON
+func MarshalJSON(m proto.Message) ([]byte, error) {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	convertIDs(v, func(s string) string {
+		if id, err := base64.StdEncoding.DecodeString(s); err == nil {
+			return hex.EncodeToString(id)
+		}
+		return s
+	})
+	return json.Marshal(v)
+}
+
+// convertIDs replaces the values of all ID fields in a decoded JSON value
+func convertIDs(v any, conv func(string) string) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, vv := range v {
+			if s, ok := vv.(string); ok && idFields[k] {
+				v[k] = conv(s)
+			} else {
+				convertIDs(vv, conv)
+			}
+		}
+	case []any:
+		for _, vv := range v {
+			convertIDs(vv, conv)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltraces "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		json   string
+		trace  string
+		span   string
+		parent string
+		err    bool
+	}{
+		{
+			name:   "hex IDs",
+			json:   `{"resourceSpans": [{"scopeSpans": [{"spans": [{"traceId": "5b8efff798038103d269b633813fc60c", "spanId": "eee19b7ec3c1b174", "parentSpanId": "eee19b7ec3c1b173"}]}]}]}`,
+			trace:  "5b8efff798038103d269b633813fc60c",
+			span:   "eee19b7ec3c1b174",
+			parent: "eee19b7ec3c1b173",
+		},
+		{
+			name:  "snake case",
+			json:  `{"resource_spans": [{"scope_spans": [{"spans": [{"trace_id": "5b8efff798038103d269b633813fc60c", "span_id": "eee19b7ec3c1b174"}]}]}]}`,
+			trace: "5b8efff798038103d269b633813fc60c",
+			span:  "eee19b7ec3c1b174",
+		},
+		{
+			name:  "base64 IDs",
+			json:  `{"resourceSpans": [{"scopeSpans": [{"spans": [{"traceId": "W47/95gDgQPSabYzgT/GDA==", "spanId": "7uGbfsPBsXQ="}]}]}]}`,
+			trace: "5b8efff798038103d269b633813fc60c",
+			span:  "eee19b7ec3c1b174",
+		},
+		{
+			name: "invalid ID",
+			json: `{"resourceSpans": [{"scopeSpans": [{"spans": [{"traceId": "not an ID"}]}]}]}`,
+			err:  true,
+		},
+		{
+			name: "invalid JSON",
+			json: `{"resourceSpans": [`,
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req coltraces.ExportTraceServiceRequest
+			err := unmarshalJSON([]byte(tt.json), &req)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("unmarshalJSON() = %v, want an error", &req)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+			if got := hex.EncodeToString(s.TraceId); got != tt.trace {
+				t.Errorf("trace ID %s, want %s", got, tt.trace)
+			}
+			if got := hex.EncodeToString(s.SpanId); got != tt.span {
+				t.Errorf("span ID %s, want %s", got, tt.span)
+			}
+			if got := hex.EncodeToString(s.ParentSpanId); got != tt.parent {
+				t.Errorf("parent span ID %s, want %s", got, tt.parent)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONLogs(t *testing.T) {
+	b := `{"resourceLogs": [{"scopeLogs": [{"logRecords": [{"timeUnixNano": "1700000000000000000", "traceId": "5b8efff798038103d269b633813fc60c", "spanId": "eee19b7ec3c1b174", "body": {"stringValue": "hi"}}]}]}]}`
+	var req collogs.ExportLogsServiceRequest
+	if err := unmarshalJSON([]byte(b), &req); err != nil {
+		t.Fatal(err)
+	}
+	l := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	if hex.EncodeToString(l.TraceId) != "5b8efff798038103d269b633813fc60c" || hex.EncodeToString(l.SpanId) != "eee19b7ec3c1b174" {
+		t.Errorf("trace ID %x and span ID %x", l.TraceId, l.SpanId)
+	}
+	if l.TimeUnixNano != 1700000000000000000 || l.Body.GetStringValue() != "hi" {
+		t.Errorf("log %v", l)
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	tests := []struct {
+		json string
+		want proto.Message
+		err  string
+	}{
+		{json: `{"resourceLogs": []}`, want: &collogs.ExportLogsServiceRequest{}},
+		{json: `{"resource_spans": []}`, want: &coltraces.ExportTraceServiceRequest{}},
+		{json: `{"resourceMetrics": [{}]}`, want: &colmetrics.ExportMetricsServiceRequest{}},
+		{json: `{"other": []}`, err: "not an OTLP export request"},
+		{json: `[]`, err: "cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			got, err := decodeRequest([]byte(tt.json))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("decodeRequest() error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ProtoReflect().Descriptor() != tt.want.ProtoReflect().Descriptor() {
+				t.Errorf("decodeRequest() = %T, want %T", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	b := `{"resourceSpans":[{"scopeSpans":[{"spans":[{"name":"GET","spanId":"eee19b7ec3c1b174","traceId":"5b8efff798038103d269b633813fc60c"}]}]}]}`
+	var req coltraces.ExportTraceServiceRequest
+	if err := unmarshalJSON([]byte(b), &req); err != nil {
+		t.Fatal(err)
+	}
+	got, err := MarshalJSON(&req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != b {
+		t.Errorf("MarshalJSON() = %s, want %s", got, b)
+	}
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// promFamily is a metric family of the Prometheus text or OpenMetrics exposition format
+type promFamily struct {
+	name    string
+	typ     string
+	help    string
+	unit    string
+	samples []promSample
+}
+
+type promSample struct {
+	name   string
+	labels []promLabel
+	value  float64
+	ts     int64 // milliseconds, 0 if not set
+}
+
+type promLabel struct{ name, value string }
+
+// promSuffixes are the suffixes of sample names that belong to a family
+var promSuffixes = []string{"_bucket", "_sum", "_count", "_total", "_created", "_gcount", "_gsum", "_info"}
+
+// parsePrometheus parses the Prometheus text exposition format, and the OpenMetrics format which extends it
+func parsePrometheus(r io.Reader) ([]*promFamily, error) {
+	var (
+		families []*promFamily
+		byName   = map[string]*promFamily{}
+		current  *promFamily
+	)
+	family := func(name string) *promFamily {
+		if f, ok := byName[name]; ok {
+			return f
+		}
+		f := &promFamily{name: name, typ: "untyped"}
+		byName[name] = f
+		families = append(families, f)
+		return f
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if comment, ok := strings.CutPrefix(line, "#"); ok {
+			fields := strings.SplitN(strings.TrimSpace(comment), " ", 3)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[0] {
+			case "TYPE":
+				current = family(fields[1])
+				current.typ = strings.ToLower(fields[2])
+			case "HELP":
+				current = family(fields[1])
+				current.help = fields[2]
+			case "UNIT":
+				current = family(fields[1])
+				current.unit = fields[2]
+			}
+			continue
+		}
+
+		s, err := parsePromSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if current == nil || !promBelongs(s.name, current.name) {
+			current = family(s.name)
+		}
+		current.samples = append(current.samples, s)
+	}
+	return families, sc.Err()
+}
+
+func promBelongs(sample, family string) bool {
+	if sample == family {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(sample, family)
+	return ok && slices.Contains(promSuffixes, suffix)
+}
+
+// parsePromSample parses a line like `name{label="value"} 1.5 1700000000000`
+func parsePromSample(line string) (promSample, error) {
+	var s promSample
+	i := strings.IndexAny(line, "{ \t")
+	if i < 0 {
+		return s, fmt.Errorf("missing value in %q", line)
+	}
+	s.name, line = line[:i], line[i:]
+
+	if strings.HasPrefix(line, "{") {
+		var err error
+		if s.labels, line, err = parsePromLabels(line); err != nil {
+			return s, fmt.Errorf("invalid labels of %s: %w", s.name, err)
+		}
+	}
+
+	// an OpenMetrics exemplar follows a " # "
+	line, _, _ = strings.Cut(line, " # ")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return s, fmt.Errorf("missing value of %s", s.name)
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("invalid value of %s: %w", s.name, err)
+	}
+	s.value = v
+	if len(fields) > 1 {
+		// the text format has timestamps in milliseconds, OpenMetrics in seconds with decimals
+		ts, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return s, fmt.Errorf("invalid timestamp of %s: %w", s.name, err)
+		}
+		if strings.Contains(fields[1], ".") || ts < 1e11 {
+			ts *= 1000
+		}
+		s.ts = int64(ts)
+	}
+	return s, nil
+}
+
+// parsePromLabels parses labels like `{label="value",other="value"}`, returning what follows them
+func parsePromLabels(line string) ([]promLabel, string, error) {
+	var labels []promLabel
+	line = strings.TrimPrefix(line, "{")
+	for {
+		line = strings.TrimLeft(line, " \t,")
+		if strings.HasPrefix(line, "}") {
+			return labels, line[1:], nil
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 || len(line) < eq+2 || line[eq+1] != '"' {
+			return nil, "", fmt.Errorf("missing label value")
+		}
+		name := strings.TrimSpace(line[:eq])
+		value, rest, err := promUnquote(line[eq+2:])
+		if err != nil {
+			return nil, "", fmt.Errorf("label %s: %w", name, err)
+		}
+		labels = append(labels, promLabel{name, value})
+		line = rest
+	}
+}
+
+// promUnquote reads a label value up to its closing quote, returning what follows it
+func promUnquote(s string) (string, string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", fmt.Errorf("unterminated escape")
+			}
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated label value")
+}
+
+// promToOTLP converts families to OTLP metrics: counters to monotonic sums, histograms and
+// summaries to their OTLP equivalents, and everything else to gauges
+func promToOTLP(families []*promFamily, start, now time.Time) []*metrics.Metric {
+	startNano, nowNano := uint64(start.UnixNano()), uint64(now.UnixNano())
+	timeOf := func(s promSample) uint64 {
+		if s.ts != 0 {
+			return uint64(s.ts) * uint64(time.Millisecond)
+		}
+		return nowNano
+	}
+
+	var ms []*metrics.Metric
+	for _, f := range families {
+		switch f.typ {
+		case "counter":
+			byName := map[string]*metrics.Sum{}
+			for _, s := range f.samples {
+				if strings.HasSuffix(s.name, "_created") {
+					continue
+				}
+				sum, ok := byName[s.name]
+				if !ok {
+					sum = &metrics.Sum{IsMonotonic: true, AggregationTemporality: metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE}
+					byName[s.name] = sum
+					ms = append(ms, &metrics.Metric{Name: s.name, Description: f.help, Unit: f.unit, Data: &metrics.Metric_Sum{Sum: sum}})
+				}
+				sum.DataPoints = append(sum.DataPoints, &metrics.NumberDataPoint{
+					Attributes:        promAttributes(s.labels, ""),
+					StartTimeUnixNano: startNano,
+					TimeUnixNano:      timeOf(s),
+					Value:             &metrics.NumberDataPoint_AsDouble{AsDouble: s.value},
+				})
+			}
+		case "histogram":
+			h := &metrics.Histogram{AggregationTemporality: metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE}
+			for _, g := range promGroups(f.samples, "le") {
+				dp := &metrics.HistogramDataPoint{Attributes: g.attrs, StartTimeUnixNano: startNano, TimeUnixNano: nowNano}
+				type bucket struct{ le, count float64 }
+				var buckets []bucket
+				hasCount := false
+				for _, s := range g.samples {
+					dp.TimeUnixNano = timeOf(s)
+					switch s.name {
+					case f.name + "_bucket":
+						le, err := strconv.ParseFloat(s.label("le"), 64)
+						if err == nil {
+							buckets = append(buckets, bucket{le, s.value})
+						}
+					case f.name + "_sum":
+						dp.Sum = &s.value
+					case f.name + "_count":
+						dp.Count, hasCount = uint64(s.value), true
+					}
+				}
+				sort.Slice(buckets, func(i, j int) bool { return buckets[i].le < buckets[j].le })
+				if len(buckets) > 0 && !math.IsInf(buckets[len(buckets)-1].le, 1) {
+					buckets = append(buckets, bucket{math.Inf(1), float64(dp.Count)})
+				}
+				prev := 0.0
+				for _, b := range buckets {
+					if !math.IsInf(b.le, 1) {
+						dp.ExplicitBounds = append(dp.ExplicitBounds, b.le)
+					}
+					dp.BucketCounts = append(dp.BucketCounts, uint64(max(b.count-prev, 0)))
+					prev = b.count
+				}
+				if !hasCount && len(buckets) > 0 {
+					dp.Count = uint64(prev)
+				}
+				h.DataPoints = append(h.DataPoints, dp)
+			}
+			ms = append(ms, &metrics.Metric{Name: f.name, Description: f.help, Unit: f.unit, Data: &metrics.Metric_Histogram{Histogram: h}})
+		case "summary":
+			sum := &metrics.Summary{}
+			for _, g := range promGroups(f.samples, "quantile") {
+				dp := &metrics.SummaryDataPoint{Attributes: g.attrs, StartTimeUnixNano: startNano, TimeUnixNano: nowNano}
+				for _, s := range g.samples {
+					dp.TimeUnixNano = timeOf(s)
+					switch s.name {
+					case f.name:
+						q, err := strconv.ParseFloat(s.label("quantile"), 64)
+						if err == nil {
+							dp.QuantileValues = append(dp.QuantileValues, &metrics.SummaryDataPoint_ValueAtQuantile{Quantile: q, Value: s.value})
+						}
+					case f.name + "_sum":
+						dp.Sum = s.value
+					case f.name + "_count":
+						dp.Count = uint64(s.value)
+					}
+				}
+				sum.DataPoints = append(sum.DataPoints, dp)
+			}
+			ms = append(ms, &metrics.Metric{Name: f.name, Description: f.help, Unit: f.unit, Data: &metrics.Metric_Summary{Summary: sum}})
+		default:
+			byName := map[string]*metrics.Gauge{}
+			for _, s := range f.samples {
+				g, ok := byName[s.name]
+				if !ok {
+					g = &metrics.Gauge{}
+					byName[s.name] = g
+					ms = append(ms, &metrics.Metric{Name: s.name, Description: f.help, Unit: f.unit, Data: &metrics.Metric_Gauge{Gauge: g}})
+				}
+				g.DataPoints = append(g.DataPoints, &metrics.NumberDataPoint{
+					Attributes:   promAttributes(s.labels, ""),
+					TimeUnixNano: timeOf(s),
+					Value:        &metrics.NumberDataPoint_AsDouble{AsDouble: s.value},
+				})
+			}
+		}
+	}
+	return ms
+}
+
+func (s promSample) label(name string) string {
+	for _, l := range s.labels {
+		if l.name == name {
+			return l.value
+		}
+	}
+	return ""
+}
+
+// promGroup are the samples of a histogram or summary with the same labels, except for le or quantile
+type promGroup struct {
+	attrs   []*v1.KeyValue
+	samples []promSample
+}
+
+func promGroups(samples []promSample, except string) []*promGroup {
+	var groups []*promGroup
+	byKey := map[string]*promGroup{}
+	for _, s := range samples {
+		attrs := promAttributes(s.labels, except)
+		key := serializeAttributes("", attrs)
+		g, ok := byKey[key]
+		if !ok {
+			g = &promGroup{attrs: attrs}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.samples = append(g.samples, s)
+	}
+	return groups
+}
+
+func promAttributes(labels []promLabel, except string) []*v1.KeyValue {
+	attrs := make([]*v1.KeyValue, 0, len(labels))
+	for _, l := range labels {
+		if l.name != except {
+			attrs = append(attrs, stringAttr(l.name, l.value))
+		}
+	}
+	return attrs
+}
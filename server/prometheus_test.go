@@ -0,0 +1,176 @@
+package server
+
+import (
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func TestParsePromSample(t *testing.T) {
+	tests := []struct {
+		line string
+		want promSample
+		err  string
+	}{
+		{line: `up 1`, want: promSample{name: "up", value: 1}},
+		{line: `temp_celsius -3.5e1`, want: promSample{name: "temp_celsius", value: -35}},
+		{line: `http_requests_total{method="post",code="200"} 1027 1395066363000`, want: promSample{
+			name: "http_requests_total", labels: []promLabel{{"method", "post"}, {"code", "200"}}, value: 1027, ts: 1395066363000,
+		}},
+		{line: `msg{text="a \"quoted\"\\ line\nbreak, {}"} 1`, want: promSample{
+			name: "msg", labels: []promLabel{{"text", "a \"quoted\"\\ line\nbreak, {}"}}, value: 1,
+		}},
+		{line: `x{a="1",} +Inf`, want: promSample{name: "x", labels: []promLabel{{"a", "1"}}, value: math.Inf(1)}},
+		{line: `x{} 2`, want: promSample{name: "x", value: 2}},
+		{line: `om_seconds 2.5 1700000000.250`, want: promSample{name: "om_seconds", value: 2.5, ts: 1700000000250}},
+		{line: `om_total 3 1700000000`, want: promSample{name: "om_total", value: 3, ts: 1700000000000}},
+		{line: `om_bucket{le="0.5"} 7 # {trace_id="abc"} 0.4 1700000000`, want: promSample{
+			name: "om_bucket", labels: []promLabel{{"le", "0.5"}}, value: 7,
+		}},
+		{line: `up`, err: "missing value"},
+		{line: `up{a="1"}`, err: "missing value of up"},
+		{line: `up one`, err: "invalid value of up"},
+		{line: `up 1 soon`, err: "invalid timestamp of up"},
+		{line: `up{a=1} 1`, err: "invalid label"},
+		{line: `up{a="1} 1`, err: "unterminated label value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parsePromSample(tt.line)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parsePromSample(%q) error %v, want %q", tt.line, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePromSample(%q) error: %v", tt.line, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePromSample(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+// testExposition is in the OpenMetrics format, where counter families are named without _total
+const testExposition = `# HELP http_requests The total number of HTTP requests.
+# TYPE http_requests counter
+http_requests_total{method="post",code="200"} 1027
+http_requests_total{method="post",code="400"} 3
+http_requests_created{method="post",code="200"} 1395066363
+
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 4773
+rpc_duration_seconds{quantile="0.99"} 76656
+rpc_duration_seconds_sum 1.7560473e+07
+rpc_duration_seconds_count 2693
+
+# TYPE request_seconds histogram
+# UNIT request_seconds seconds
+request_seconds_bucket{path="/",le="0.1"} 2
+request_seconds_bucket{path="/",le="1"} 5
+request_seconds_bucket{path="/",le="+Inf"} 6
+request_seconds_sum{path="/"} 3.5
+request_seconds_count{path="/"} 6
+request_seconds_bucket{path="/a",le="1"} 1
+request_seconds_count{path="/a"} 4
+
+# a comment
+temperature 21.5 1700000000000
+`
+
+func TestParsePrometheus(t *testing.T) {
+	families, err := parsePrometheus(strings.NewReader(testExposition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range families {
+		got = append(got, f.name+" "+f.typ)
+	}
+	want := []string{"http_requests counter", "rpc_duration_seconds summary", "request_seconds histogram", "temperature untyped"}
+	if !slices.Equal(got, want) {
+		t.Errorf("families %v, want %v", got, want)
+	}
+
+	if _, err := parsePrometheus(strings.NewReader("up 1\nup{ 1\n")); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("parsePrometheus() error %v, want the line of the invalid sample", err)
+	}
+}
+
+func TestPromToOTLP(t *testing.T) {
+	families, err := parsePrometheus(strings.NewReader(testExposition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, now := time.Unix(100, 0), time.Unix(200, 0)
+	byName := map[string]*metrics.Metric{}
+	for _, m := range promToOTLP(families, start, now) {
+		byName[m.Name] = m
+	}
+
+	tests := []struct {
+		name  string
+		check func(*testing.T, *metrics.Metric)
+	}{
+		{"http_requests_total", func(t *testing.T, m *metrics.Metric) {
+			sum := m.GetSum()
+			if sum == nil || !sum.IsMonotonic || len(sum.DataPoints) != 2 || m.Description != "The total number of HTTP requests." {
+				t.Fatalf("metric %v, want a monotonic sum of 2 datapoints", m)
+			}
+			dp := sum.DataPoints[0]
+			if dp.GetAsDouble() != 1027 || dp.StartTimeUnixNano != uint64(start.UnixNano()) || dp.TimeUnixNano != uint64(now.UnixNano()) {
+				t.Errorf("datapoint %v", dp)
+			}
+			if attrs := attrStrings(dp.Attributes); attrs["method"] != "post" || attrs["code"] != "200" {
+				t.Errorf("attributes %v", attrs)
+			}
+		}},
+		{"rpc_duration_seconds", func(t *testing.T, m *metrics.Metric) {
+			dp := m.GetSummary().DataPoints[0]
+			if dp.Count != 2693 || dp.Sum != 1.7560473e+07 || len(dp.QuantileValues) != 2 || dp.QuantileValues[1].Quantile != 0.99 {
+				t.Errorf("datapoint %v", dp)
+			}
+		}},
+		{"request_seconds", func(t *testing.T, m *metrics.Metric) {
+			h := m.GetHistogram()
+			if m.Unit != "seconds" || len(h.DataPoints) != 2 {
+				t.Fatalf("metric %v, want a histogram in seconds of 2 datapoints", m)
+			}
+			dp := h.DataPoints[0]
+			if !slices.Equal(dp.ExplicitBounds, []float64{0.1, 1}) || !slices.Equal(dp.BucketCounts, []uint64{2, 3, 1}) ||
+				dp.Count != 6 || dp.GetSum() != 3.5 {
+				t.Errorf("datapoint %v, want buckets 2, 3 and 1", dp)
+			}
+			// the +Inf bucket is missing, so it holds the rest of the count
+			dp = h.DataPoints[1]
+			if !slices.Equal(dp.ExplicitBounds, []float64{1}) || !slices.Equal(dp.BucketCounts, []uint64{1, 3}) || dp.Count != 4 {
+				t.Errorf("datapoint %v, want buckets 1 and 3", dp)
+			}
+		}},
+		{"temperature", func(t *testing.T, m *metrics.Metric) {
+			dp := m.GetGauge().DataPoints[0]
+			if dp.GetAsDouble() != 21.5 || dp.TimeUnixNano != uint64(time.UnixMilli(1700000000000).UnixNano()) {
+				t.Errorf("datapoint %v", dp)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, ok := byName[tt.name]
+			if !ok {
+				t.Fatalf("no metric %s", tt.name)
+			}
+			tt.check(t, m)
+		})
+	}
+	if _, ok := byName["http_requests_created"]; ok {
+		t.Error("_created samples of counters became a metric")
+	}
+}
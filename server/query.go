@@ -22,7 +22,7 @@ func GetTraces() []*Trace {
 	res := make([]*Trace, len(Storage.traceOrder))
 	for i, id := range Storage.traceOrder {
 		orig := Storage.traces[id]
-		t := &Trace{TraceID: orig.TraceID, Spans: make([]*Span, len(orig.Spans))}
+		t := &Trace{TraceID: orig.TraceID, Received: orig.Received, Spans: make([]*Span, len(orig.Spans))}
 		copy(t.Spans, orig.Spans)
 		res[i] = t
 	}
@@ -54,5 +54,9 @@ func GetDatapoints(name string) *Datapoints {
 	}
 	copy(res.Times, m.Times)
 	copy(res.Values, m.Values)
+	if m.Histograms != nil {
+		res.Histograms = make([]*Histogram, len(m.Histograms))
+		copy(res.Histograms, m.Histograms)
+	}
 	return res
 }
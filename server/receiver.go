@@ -2,15 +2,20 @@ package server
 
 import (
 	"context"
-	"io"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	logs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	metrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	traces "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // registers the gzip compressor
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
@@ -26,57 +31,195 @@ type metricsReceiver struct {
 	metrics.UnimplementedMetricsServiceServer
 }
 
-// NewReceiver creates a new OTLP receiver
-func Start(ctx context.Context, cancel context.CancelFunc) {
-	setupStorage()
+// Config controls which receivers are started and where they listen
+type Config struct {
+	// GRPCAddr is the listen address of the OTLP gRPC receiver, empty disables it
+	GRPCAddr string
+	// HTTPAddr is the listen address of the OTLP HTTP receiver, empty disables it
+	HTTPAddr string
+	// TLS enables TLS on both receivers
+	TLS TLS
+	// MaxBodySize is the maximum size of a decompressed OTLP/HTTP request in bytes, 0 for unlimited
+	MaxBodySize int
+	// Limits bounds the telemetry retained in Storage
+	Limits Limits
+	// DataDir keeps received telemetry on disk to reload it on startup, empty disables it
+	DataDir string
+	// MaxWALSize is the maximum size of the telemetry kept in DataDir in bytes, 0 for unlimited
+	MaxWALSize int
+	// Record is a file to record every received request to, empty disables it
+	Record string
+	// Scrape are Prometheus metrics endpoints to scrape
+	Scrape []ScrapeTarget
+	// StatsDAddr is the UDP listen address of the StatsD receiver, empty disables it
+	StatsDAddr string
+	// StatsDFlush is how often aggregated StatsD metrics are stored
+	StatsDFlush time.Duration
+	// SyslogUDP and SyslogTCP are the listen addresses of the syslog receivers, empty disables them
+	SyslogUDP string
+	SyslogTCP string
+	// Tail are files to follow and read lines of as logs, - for stdin
+	Tail []string
+	// APIAddr is the listen address of the JSON query API, empty disables it
+	APIAddr string
+	// Forward is an OTLP endpoint to forward every received request to, see newExporter, empty disables it
+	Forward string
+	// ForwardQueue is how many requests can wait to be forwarded before new ones are dropped
+	ForwardQueue int
+}
 
-	grpcListener, err := net.Listen("tcp", ":4317")
+// Start starts the OTLP receivers enabled in cfg
+func Start(ctx context.Context, cancel context.CancelFunc, cfg Config) error {
+	tlsCfg, err := tlsConfig(cfg.TLS)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to listen on gRPC port 4317", "err", err)
-		cancel()
-		return
+		return err
+	}
+
+	setupStorage(cfg.Limits)
+	maxBodySize = int64(cfg.MaxBodySize)
+
+	if cfg.DataDir != "" {
+		if err := openWAL(cfg.DataDir, int64(cfg.MaxWALSize), cfg.Limits.MaxAge); err != nil {
+			return err
+		}
+	}
+	if cfg.Record != "" {
+		if err := openRecording(cfg.Record); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Forward != "" {
+		if err := startForwarding(ctx, cfg.Forward, cfg.ForwardQueue); err != nil {
+			return err
+		}
 	}
 
-	grpcServer := grpc.NewServer(
-		grpc.MaxRecvMsgSize(4*1024*1024), // 4MB max receive message size
-		grpc.MaxSendMsgSize(4*1024*1024), // 4MB max send message size
-	)
+	if cfg.StatsDAddr != "" {
+		pc, err := net.ListenPacket("udp", cfg.StatsDAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for StatsD on %s: %w", cfg.StatsDAddr, err)
+		}
+		flush := cfg.StatsDFlush
+		if flush <= 0 {
+			flush = defaultStatsDFlush
+		}
+		go listenStatsD(ctx, pc, flush)
+	}
+	if cfg.SyslogUDP != "" {
+		pc, err := net.ListenPacket("udp", cfg.SyslogUDP)
+		if err != nil {
+			return fmt.Errorf("failed to listen for syslog on UDP %s: %w", cfg.SyslogUDP, err)
+		}
+		go listenSyslogUDP(ctx, pc)
+	}
+	if cfg.SyslogTCP != "" {
+		l, err := net.Listen("tcp", cfg.SyslogTCP)
+		if err != nil {
+			return fmt.Errorf("failed to listen for syslog on TCP %s: %w", cfg.SyslogTCP, err)
+		}
+		go listenSyslogTCP(ctx, l)
+	}
 
 	lr := &logsReceiver{}
 	tr := &tracesReceiver{}
 	mr := &metricsReceiver{}
 
-	logs.RegisterLogsServiceServer(grpcServer, lr)
-	traces.RegisterTraceServiceServer(grpcServer, tr)
-	metrics.RegisterMetricsServiceServer(grpcServer, mr)
+	var grpcServer *grpc.Server
+	if cfg.GRPCAddr != "" {
+		grpcListener, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for OTLP gRPC on %s: %w", cfg.GRPCAddr, err)
+		}
 
-	go func() {
-		if err := grpcServer.Serve(grpcListener); err != nil && err != grpc.ErrServerStopped {
-			slog.ErrorContext(ctx, "OTLP gRPC receiver serve error", "err", err)
-			cancel()
+		opts := []grpc.ServerOption{
+			grpc.MaxRecvMsgSize(4 * 1024 * 1024), // 4MB max receive message size
+			grpc.MaxSendMsgSize(4 * 1024 * 1024), // 4MB max send message size
 		}
-	}()
+		if tlsCfg != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+		}
+		grpcServer = grpc.NewServer(opts...)
 
-	httpListener, err := net.Listen("tcp", ":4318")
-	if err != nil {
-		slog.ErrorContext(ctx, "failed to listen on HTTP port 4318", "err", err)
-		cancel()
-		return
+		logs.RegisterLogsServiceServer(grpcServer, lr)
+		traces.RegisterTraceServiceServer(grpcServer, tr)
+		metrics.RegisterMetricsServiceServer(grpcServer, mr)
+
+		go func() {
+			if err := grpcServer.Serve(grpcListener); err != nil && err != grpc.ErrServerStopped {
+				slog.ErrorContext(ctx, "OTLP gRPC receiver serve error", "err", err)
+				cancel()
+			}
+		}()
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/v1/logs", lr.handle)
-	mux.HandleFunc("/v1/traces", tr.handle)
-	mux.HandleFunc("/v1/metrics", mr.handle)
+	var httpServer *http.Server
+	if cfg.HTTPAddr != "" {
+		httpListener, err := net.Listen("tcp", cfg.HTTPAddr)
+		if err != nil {
+			if grpcServer != nil {
+				grpcServer.Stop()
+			}
+			return fmt.Errorf("failed to listen for OTLP HTTP on %s: %w", cfg.HTTPAddr, err)
+		}
 
-	httpServer := &http.Server{Handler: mux}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1/logs", lr.handle)
+		mux.HandleFunc("/v1/traces", tr.handle)
+		mux.HandleFunc("/v1/metrics", mr.handle)
+		mux.HandleFunc("/api/v2/spans", handleZipkin)
+		mux.HandleFunc("/api/traces", handleJaeger)
+		mux.HandleFunc("/api/v1/write", handleRemoteWrite)
+		mux.HandleFunc("/loki/api/v1/push", handleLoki)
 
-	go func() {
-		if err := httpServer.Serve(httpListener); err != nil && err != http.ErrServerClosed {
-			slog.ErrorContext(ctx, "OTLP HTTP receiver serve error", "err", err)
-			cancel()
+		httpServer = &http.Server{
+			Handler:   mux,
+			TLSConfig: tlsCfg,
+			// eg. failed TLS handshakes would otherwise be written over the UI
+			ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelDebug),
 		}
-	}()
+
+		go func() {
+			var err error
+			if tlsCfg != nil {
+				err = httpServer.ServeTLS(httpListener, "", "")
+			} else {
+				err = httpServer.Serve(httpListener)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				slog.ErrorContext(ctx, "OTLP HTTP receiver serve error", "err", err)
+				cancel()
+			}
+		}()
+	}
+
+	var apiServer *http.Server
+	if cfg.APIAddr != "" {
+		apiListener, err := net.Listen("tcp", cfg.APIAddr)
+		if err != nil {
+			if grpcServer != nil {
+				grpcServer.Stop()
+			}
+			if httpServer != nil {
+				httpServer.Close()
+			}
+			return fmt.Errorf("failed to listen for the API on %s: %w", cfg.APIAddr, err)
+		}
+		apiServer = &http.Server{Handler: apiHandler(), ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelDebug)}
+		go func() {
+			if err := apiServer.Serve(apiListener); err != nil && err != http.ErrServerClosed {
+				slog.ErrorContext(ctx, "API serve error", "err", err)
+				cancel()
+			}
+		}()
+	}
+
+	for _, t := range cfg.Scrape {
+		go scrape(ctx, t)
+	}
+	for _, path := range cfg.Tail {
+		go tail(ctx, path)
+	}
 
 	go func() {
 		<-ctx.Done()
@@ -87,89 +230,196 @@ func Start(ctx context.Context, cancel context.CancelFunc) {
 		if httpServer != nil {
 			httpServer.Shutdown(context.Background())
 		}
+		if apiServer != nil {
+			apiServer.Shutdown(context.Background())
+		}
+		closeFiles()
 	}()
+
+	return nil
+}
+
+// receive persists and stores a request that has just arrived, returning which records were invalid
+func receive(req proto.Message) rejection {
+	now := time.Now().UTC()
+	persist(now, req)
+	return ingest(now, req)
+}
+
+// ingest stores a request received at the given time
+func ingest(received time.Time, req proto.Message) rejection {
+	switch req := req.(type) {
+	case *logs.ExportLogsServiceRequest:
+		return consumeLogs(req.ResourceLogs, received)
+	case *traces.ExportTraceServiceRequest:
+		return consumeTraces(req.ResourceSpans, received)
+	case *metrics.ExportMetricsServiceRequest:
+		return consumeMetrics(req.ResourceMetrics, received)
+	}
+	return rejection{}
 }
 
 // Export implements the OTLP logs service Export method
 func (r *logsReceiver) Export(ctx context.Context, req *logs.ExportLogsServiceRequest) (*logs.ExportLogsServiceResponse, error) {
-	consumeLogs(req.ResourceLogs)
-	return &logs.ExportLogsServiceResponse{}, nil
+	return logsResponse(receiveGRPC(ctx, req)), nil
 }
 
 func (r *tracesReceiver) ExportTraces(ctx context.Context, req *traces.ExportTraceServiceRequest) (*traces.ExportTraceServiceResponse, error) {
-	consumeTraces(req.ResourceSpans)
-	return &traces.ExportTraceServiceResponse{}, nil
+	return tracesResponse(receiveGRPC(ctx, req)), nil
 }
 
 func (r *metricsReceiver) ExportMetrics(ctx context.Context, req *metrics.ExportMetricsServiceRequest) (*metrics.ExportMetricsServiceResponse, error) {
-	consumeMetrics(req.ResourceMetrics)
-	return &metrics.ExportMetricsServiceResponse{}, nil
+	return metricsResponse(receiveGRPC(ctx, req)), nil
+}
+
+func receiveGRPC(ctx context.Context, req proto.Message) rejection {
+	r := grpcRequest(ctx)
+	r.bytes = proto.Size(req)
+	forward(req)
+	rej := receive(req)
+	observe(r, req, rej)
+	return rej
+}
+
+// logsResponse reports invalid records as a partial success, which is left unset if there were none
+func logsResponse(rej rejection) *logs.ExportLogsServiceResponse {
+	res := &logs.ExportLogsServiceResponse{}
+	if rej.count > 0 {
+		res.PartialSuccess = &logs.ExportLogsPartialSuccess{RejectedLogRecords: int64(rej.count), ErrorMessage: rej.message()}
+	}
+	return res
 }
 
-func handle(w http.ResponseWriter, req *http.Request, payload, res proto.Message) (success bool) {
+func tracesResponse(rej rejection) *traces.ExportTraceServiceResponse {
+	res := &traces.ExportTraceServiceResponse{}
+	if rej.count > 0 {
+		res.PartialSuccess = &traces.ExportTracePartialSuccess{RejectedSpans: int64(rej.count), ErrorMessage: rej.message()}
+	}
+	return res
+}
+
+func metricsResponse(rej rejection) *metrics.ExportMetricsServiceResponse {
+	res := &metrics.ExportMetricsServiceResponse{}
+	if rej.count > 0 {
+		res.PartialSuccess = &metrics.ExportMetricsPartialSuccess{RejectedDataPoints: int64(rej.count), ErrorMessage: rej.message()}
+	}
+	return res
+}
+
+// readRequest reads a POSTed request and translates its body to OTLP with decode. If that fails, it
+// responds with the error, adds the request to the stats and returns nil.
+func readRequest(w http.ResponseWriter, req *http.Request, r *request, decode func(body []byte) (proto.Message, error)) proto.Message {
 	if req.Method != http.MethodPost {
+		observe(r, nil, rejection{})
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return false
+		return nil
 	}
 
-	body, err := io.ReadAll(req.Body)
+	defer req.Body.Close()
+	body, err := readBody(w, req)
+	r.bytes = len(body)
+	if errors.Is(err, errBodyTooLarge) {
+		observe(r, nil, rejection{})
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return nil
+	}
 	if err != nil {
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return false
+		observe(r, nil, rejection{})
+		http.Error(w, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
+		return nil
 	}
-	defer req.Body.Close()
 
-	switch req.Header.Get("Content-Type") {
-	case "application/x-protobuf", "application/protobuf":
-		if err := proto.Unmarshal(body, payload); err != nil {
-			http.Error(w, "Failed to unmarshal protobuf", http.StatusBadRequest)
-			return false
-		}
-	case "application/json":
-		if err := protojson.Unmarshal(body, payload); err != nil {
-			http.Error(w, "Failed to unmarshal JSON", http.StatusBadRequest)
-			return false
-		}
-	default:
-		if err := proto.Unmarshal(body, payload); err != nil {
-			if err := protojson.Unmarshal(body, payload); err != nil {
-				http.Error(w, "Failed to unmarshal request", http.StatusBadRequest)
-				return false
+	payload, err := decode(body)
+	var unsupported unsupportedError
+	if errors.As(err, &unsupported) {
+		observe(r, nil, rejection{})
+		http.Error(w, string(unsupported), http.StatusUnsupportedMediaType)
+		return nil
+	}
+	if err != nil {
+		observe(r, nil, rejection{})
+		http.Error(w, "Failed to unmarshal request: "+err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	return payload
+}
+
+// unsupportedError is returned by decoders for bodies of a format they don't support
+type unsupportedError string
+
+func (e unsupportedError) Error() string { return string(e) }
+
+// handleHTTP receives telemetry POSTed to an API other than OTLP, which decode translates to OTLP.
+// These APIs have no partial success, so the response only has the number of rejected records in
+// rejectedHeader, which is informative.
+func handleHTTP(w http.ResponseWriter, req *http.Request, transport string, status int, rejectedHeader string, decode func(body []byte) (proto.Message, error)) {
+	r := httpRequest(req, transport)
+	payload := readRequest(w, req, r, decode)
+	if payload == nil {
+		return
+	}
+	rej := receive(payload)
+	observe(r, payload, rej)
+	if rej.count > 0 {
+		w.Header().Set(rejectedHeader, strconv.Itoa(rej.count))
+	}
+	w.WriteHeader(status)
+}
+
+// handle decodes an OTLP/HTTP request into payload, receives it and responds with what respond returns
+func handle(w http.ResponseWriter, req *http.Request, payload proto.Message, respond func(rejection) proto.Message) {
+	r := httpRequest(req, TransportHTTPProto)
+	if req.Header.Get("Content-Type") == "application/json" {
+		r.transport = TransportHTTPJSON
+	}
+	decoded := readRequest(w, req, r, func(body []byte) (proto.Message, error) {
+		switch req.Header.Get("Content-Type") {
+		case "application/x-protobuf", "application/protobuf":
+			if err := proto.Unmarshal(body, payload); err != nil {
+				return nil, fmt.Errorf("invalid protobuf: %w", err)
+			}
+		case "application/json":
+			if err := unmarshalJSON(body, payload); err != nil {
+				return nil, fmt.Errorf("invalid JSON: %w", err)
+			}
+		default:
+			if err := proto.Unmarshal(body, payload); err != nil {
+				if err := unmarshalJSON(body, payload); err != nil {
+					return nil, errors.New("neither protobuf nor JSON")
+				}
+				r.transport = TransportHTTPJSON
 			}
 		}
+		return payload, nil
+	})
+	if decoded == nil {
+		return
 	}
 
+	forward(payload)
+	rej := receive(payload)
+	observe(r, payload, rej)
+	res := respond(rej)
 	switch req.Header.Get("Accept") {
 	case "application/json":
 		w.Header().Set("Content-Type", "application/json")
 		jsonBytes, _ := protojson.Marshal(res)
-		w.Write(jsonBytes)
+		writeBody(w, req, jsonBytes)
 	default:
 		w.Header().Set("Content-Type", "application/x-protobuf")
 		protoBytes, _ := proto.Marshal(res)
-		w.Write(protoBytes)
+		writeBody(w, req, protoBytes)
 	}
-
-	return true
 }
 
 func (r *logsReceiver) handle(w http.ResponseWriter, req *http.Request) {
-	var payload logs.ExportLogsServiceRequest
-	if handle(w, req, &payload, &logs.ExportLogsServiceResponse{}) {
-		consumeLogs(payload.ResourceLogs)
-	}
+	handle(w, req, &logs.ExportLogsServiceRequest{}, func(rej rejection) proto.Message { return logsResponse(rej) })
 }
 
 func (r *tracesReceiver) handle(w http.ResponseWriter, req *http.Request) {
-	var payload traces.ExportTraceServiceRequest
-	if handle(w, req, &payload, &traces.ExportTraceServiceResponse{}) {
-		consumeTraces(payload.ResourceSpans)
-	}
+	handle(w, req, &traces.ExportTraceServiceRequest{}, func(rej rejection) proto.Message { return tracesResponse(rej) })
 }
 
 func (r *metricsReceiver) handle(w http.ResponseWriter, req *http.Request) {
-	var payload metrics.ExportMetricsServiceRequest
-	if handle(w, req, &payload, &metrics.ExportMetricsServiceResponse{}) {
-		consumeMetrics(payload.ResourceMetrics)
-	}
+	handle(w, req, &metrics.ExportMetricsServiceRequest{}, func(rej rejection) proto.Message { return metricsResponse(rej) })
 }
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	colmetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// TransportRemoteWrite is the Prometheus remote write 1.0 protocol
+const TransportRemoteWrite = "prometheus/remote-write"
+
+// promStaleNaN marks a series as stale in remote write, it is not a real sample
+const promStaleNaN = 0x7ff0000000000002
+
+// remoteSeries is a TimeSeries of remote write, with its samples in milliseconds
+type remoteSeries struct {
+	labels  []promLabel
+	samples []remoteSample
+}
+
+type remoteSample struct {
+	value     float64
+	timestamp int64
+}
+
+// remoteMetadata is the MetricMetadata of a metric family
+type remoteMetadata struct {
+	typ  string
+	help string
+	unit string
+}
+
+// remoteMetricTypes are the MetricType values of remote write, named like # TYPE
+var remoteMetricTypes = map[uint64]string{1: "counter", 2: "gauge", 3: "histogram", 4: "gaugehistogram", 5: "summary", 6: "info", 7: "stateset"}
+
+// handleRemoteWrite receives snappy compressed WriteRequests POSTed to /api/v1/write
+func handleRemoteWrite(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Content-Encoding") == "" {
+		// the body is always snappy compressed, even if some clients don't say so
+		req.Header.Set("Content-Encoding", "snappy")
+	}
+	handleHTTP(w, req, TransportRemoteWrite, http.StatusNoContent, "X-Rejected-Datapoints", func(body []byte) (proto.Message, error) {
+		if strings.Contains(req.Header.Get("Content-Type"), "io.prometheus.write.v2") {
+			return nil, unsupportedError("Only remote write 1.0 is supported")
+		}
+		series, metadata, err := decodeWriteRequest(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WriteRequest: %w", err)
+		}
+		return remoteWriteToOTLP(series, metadata), nil
+	})
+}
+
+// remoteWriteToOTLP translates time series to metrics named by __name__, with the other labels as attributes.
+// Counters, and the buckets, sums and counts of histograms and summaries, become cumulative sums, everything else gauges.
+func remoteWriteToOTLP(series []remoteSeries, metadata map[string]remoteMetadata) *colmetrics.ExportMetricsServiceRequest {
+	var ms []*metrics.Metric
+	byName := map[string]*metrics.Metric{}
+	for _, ts := range series {
+		name := ""
+		labels := make([]promLabel, 0, len(ts.labels))
+		for _, l := range ts.labels {
+			if l.name == "__name__" {
+				name = l.value
+			} else {
+				labels = append(labels, l)
+			}
+		}
+		attrs := promAttributes(labels, "")
+
+		md, ok := metadata[name]
+		if !ok {
+			for _, suffix := range promSuffixes {
+				if md, ok = metadata[strings.TrimSuffix(name, suffix)]; ok {
+					break
+				}
+			}
+		}
+		component := strings.HasSuffix(name, "_bucket") || strings.HasSuffix(name, "_count") || strings.HasSuffix(name, "_sum")
+		cumulative := md.typ == "counter" ||
+			((md.typ == "histogram" || md.typ == "summary") && component) ||
+			(md.typ == "" && strings.HasSuffix(name, "_total"))
+
+		m, ok := byName[name]
+		if !ok {
+			m = &metrics.Metric{Name: name, Description: md.help, Unit: md.unit}
+			if cumulative {
+				m.Data = &metrics.Metric_Sum{Sum: &metrics.Sum{IsMonotonic: true, AggregationTemporality: metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE}}
+			} else {
+				m.Data = &metrics.Metric_Gauge{Gauge: &metrics.Gauge{}}
+			}
+			byName[name] = m
+			ms = append(ms, m)
+		}
+
+		for _, s := range ts.samples {
+			if math.Float64bits(s.value) == promStaleNaN {
+				continue
+			}
+			dp := &metrics.NumberDataPoint{
+				Attributes:   attrs,
+				TimeUnixNano: uint64(s.timestamp) * uint64(time.Millisecond),
+				Value:        &metrics.NumberDataPoint_AsDouble{AsDouble: s.value},
+			}
+			switch d := m.Data.(type) {
+			case *metrics.Metric_Sum:
+				d.Sum.DataPoints = append(d.Sum.DataPoints, dp)
+			case *metrics.Metric_Gauge:
+				d.Gauge.DataPoints = append(d.Gauge.DataPoints, dp)
+			}
+		}
+	}
+
+	return &colmetrics.ExportMetricsServiceRequest{ResourceMetrics: []*metrics.ResourceMetrics{{
+		Resource:     &resource.Resource{},
+		ScopeMetrics: []*metrics.ScopeMetrics{{Scope: &v1.InstrumentationScope{Name: "otelui/remote-write"}, Metrics: ms}},
+	}}}
+}
+
+// decodeWriteRequest decodes a WriteRequest of Prometheus' remote.proto, native histograms and exemplars are skipped
+func decodeWriteRequest(b []byte) ([]remoteSeries, map[string]remoteMetadata, error) {
+	var series []remoteSeries
+	metadata := map[string]remoteMetadata{}
+	err := walkProto(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			ts, err := decodeTimeSeries(v)
+			series = append(series, ts)
+			return err
+		case 3:
+			var (
+				md   remoteMetadata
+				name string
+			)
+			err := walkProto(v, func(num protowire.Number, _ protowire.Type, v []byte, n uint64) error {
+				switch num {
+				case 1:
+					md.typ = remoteMetricTypes[n]
+				case 2:
+					name = string(v)
+				case 4:
+					md.help = string(v)
+				case 5:
+					md.unit = string(v)
+				}
+				return nil
+			})
+			metadata[name] = md
+			return err
+		}
+		return nil
+	})
+	return series, metadata, err
+}
+
+func decodeTimeSeries(b []byte) (ts remoteSeries, err error) {
+	err = walkProto(b, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+		switch num {
+		case 1:
+			var l promLabel
+			if err := walkProto(v, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+				switch num {
+				case 1:
+					l.name = string(v)
+				case 2:
+					l.value = string(v)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			ts.labels = append(ts.labels, l)
+		case 2:
+			var s remoteSample
+			if err := walkProto(v, func(num protowire.Number, _ protowire.Type, _ []byte, n uint64) error {
+				switch num {
+				case 1:
+					s.value = math.Float64frombits(n)
+				case 2:
+					s.timestamp = int64(n)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			ts.samples = append(ts.samples, s)
+		}
+		return nil
+	})
+	sort.Slice(ts.samples, func(i, j int) bool { return ts.samples[i].timestamp < ts.samples[j].timestamp })
+	return ts, err
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// testTimeSeries encodes a TimeSeries of remote.proto, with samples of value and millisecond timestamp pairs
+func testTimeSeries(labels []promLabel, samples ...remoteSample) []byte {
+	var ts []byte
+	for _, l := range labels {
+		ts = protoBytes(ts, 1, protoBytes(protoBytes(nil, 1, []byte(l.name)), 2, []byte(l.value)))
+	}
+	for _, s := range samples {
+		sample := protoFixed64(nil, 1, math.Float64bits(s.value))
+		sample = protoVarint(sample, 2, uint64(s.timestamp))
+		ts = protoBytes(ts, 2, sample)
+	}
+	return ts
+}
+
+func testMetadata(typ uint64, name, help, unit string) []byte {
+	md := protoVarint(nil, 1, typ)
+	md = protoBytes(md, 2, []byte(name))
+	md = protoBytes(md, 4, []byte(help))
+	return protoBytes(md, 5, []byte(unit))
+}
+
+func TestDecodeWriteRequest(t *testing.T) {
+	var req []byte
+	req = protoBytes(req, 1, testTimeSeries(
+		[]promLabel{{"__name__", "http_requests_total"}, {"code", "200"}},
+		remoteSample{10, 2000}, remoteSample{5, 1000},
+	))
+	req = protoBytes(req, 1, testTimeSeries([]promLabel{{"__name__", "up"}}, remoteSample{1, 1000}))
+	req = protoBytes(req, 3, testMetadata(2, "up", "Whether the target is up.", ""))
+
+	series, metadata, err := decodeWriteRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []remoteSeries{
+		{labels: []promLabel{{"__name__", "http_requests_total"}, {"code", "200"}}, samples: []remoteSample{{5, 1000}, {10, 2000}}},
+		{labels: []promLabel{{"__name__", "up"}}, samples: []remoteSample{{1, 1000}}},
+	}
+	if !reflect.DeepEqual(series, want) {
+		t.Errorf("series %+v, want %+v, with samples sorted by time", series, want)
+	}
+	if md := metadata["up"]; md != (remoteMetadata{typ: "gauge", help: "Whether the target is up."}) {
+		t.Errorf("metadata %+v", md)
+	}
+
+	if _, _, err := decodeWriteRequest(req[:len(req)-2]); err == nil {
+		t.Error("decodeWriteRequest() of a truncated request succeeded")
+	}
+}
+
+func TestRemoteWriteToOTLP(t *testing.T) {
+	series := func(name string, samples ...remoteSample) remoteSeries {
+		return remoteSeries{labels: []promLabel{{"__name__", name}, {"job", "api"}}, samples: samples}
+	}
+	metadata := map[string]remoteMetadata{
+		"latency_seconds": {typ: "histogram", unit: "seconds"},
+		"queue_size":      {typ: "gauge", help: "Jobs waiting."},
+		"restarts":        {typ: "counter"},
+	}
+
+	tests := []struct {
+		series     remoteSeries
+		cumulative bool
+		values     []float64
+	}{
+		{series("queue_size", remoteSample{3, 1000}), false, []float64{3}},
+		{series("restarts", remoteSample{1, 1000}), true, []float64{1}},
+		{series("requests_total", remoteSample{7, 1000}), true, []float64{7}},
+		{series("temperature", remoteSample{21, 1000}), false, []float64{21}},
+		{series("latency_seconds_bucket", remoteSample{4, 1000}), true, []float64{4}},
+		{series("latency_seconds_count", remoteSample{4, 1000}, remoteSample{math.Float64frombits(promStaleNaN), 2000}), true, []float64{4}},
+	}
+	for _, tt := range tests {
+		name := tt.series.labels[0].value
+		t.Run(name, func(t *testing.T) {
+			req := remoteWriteToOTLP([]remoteSeries{tt.series}, metadata)
+			m := req.ResourceMetrics[0].ScopeMetrics[0].Metrics[0]
+			if m.Name != name || m.Description != metadata[name].help {
+				t.Errorf("metric %s %q", m.Name, m.Description)
+			}
+			dps := m.GetGauge().GetDataPoints()
+			if sum := m.GetSum(); sum != nil {
+				dps = sum.DataPoints
+			}
+			if (m.GetSum() != nil) != tt.cumulative {
+				t.Errorf("metric %v, want cumulative %v", m, tt.cumulative)
+			}
+			var values []float64
+			for _, dp := range dps {
+				values = append(values, dp.GetAsDouble())
+				if dp.TimeUnixNano != 1e9 || attrStrings(dp.Attributes)["job"] != "api" || len(dp.Attributes) != 1 {
+					t.Errorf("datapoint %v", dp)
+				}
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("values %v, want %v without stale markers", values, tt.values)
+			}
+		})
+	}
+}
+
+func TestDecodeSnappy(t *testing.T) {
+	body := testTimeSeries([]promLabel{{"__name__", "up"}}, remoteSample{1, 1000})
+	got, err := decodeSnappy(snappy.Encode(nil, body))
+	if err != nil || !reflect.DeepEqual(got, body) {
+		t.Errorf("decodeSnappy() = %x, %v", got, err)
+	}
+	if _, err := decodeSnappy(body); err == nil {
+		t.Error("decodeSnappy() of an uncompressed body succeeded")
+	}
+}
+
+func TestHandleRemoteWrite(t *testing.T) {
+	setupStorage(Limits{})
+	body := protoBytes(nil, 1, testTimeSeries([]promLabel{{"__name__", "up"}}, remoteSample{1, 1000}))
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        []byte
+		status      int
+	}{
+		{"snappy", "application/x-protobuf", "snappy", snappy.Encode(nil, body), http.StatusNoContent},
+		{"snappy without saying so", "application/x-protobuf", "", snappy.Encode(nil, body), http.StatusNoContent},
+		{"uncompressed", "application/x-protobuf", "", body, http.StatusBadRequest},
+		{"invalid", "application/x-protobuf", "", snappy.Encode(nil, body[:len(body)-1]), http.StatusBadRequest},
+		{"2.0", "application/x-protobuf;proto=io.prometheus.write.v2.Request", "", snappy.Encode(nil, body), http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		if tt.encoding != "" {
+			req.Header.Set("Content-Encoding", tt.encoding)
+		}
+		rec := httptest.NewRecorder()
+		handleRemoteWrite(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+	if dps := GetDatapoints(serializeAttributes("up")); dps == nil || len(dps.Values) != 2 {
+		t.Errorf("datapoints %v, want one per accepted request", dps)
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Replay feeds a recording into Storage as if its requests were just received, without persisting them,
+// keeping their relative timing sped up by speed, or as fast as possible if speed is 0.
+func Replay(ctx context.Context, r io.Reader, speed float64) error {
+	return replay(ctx, r, speed, func(req proto.Message) error {
+		ingest(time.Now().UTC(), req)
+		return nil
+	})
+}
+
+// ReplayTo sends a recording to an OTLP endpoint, see newExporter for the format of target
+func ReplayTo(ctx context.Context, r io.Reader, speed float64, target string) (int, error) {
+	exp, err := newExporter(target)
+	if err != nil {
+		return 0, err
+	}
+	defer exp.close()
+
+	sent := 0
+	err = replay(ctx, r, speed, func(req proto.Message) error {
+		if err := exp.export(ctx, req); err != nil {
+			return err
+		}
+		sent++
+		return nil
+	})
+	return sent, err
+}
+
+func replay(ctx context.Context, r io.Reader, speed float64, fn func(req proto.Message) error) error {
+	var first time.Time
+	start := time.Now()
+	_, err := readRecords(r, func(received time.Time, req proto.Message) error {
+		if first.IsZero() {
+			first = received
+		}
+		if speed > 0 {
+			at := start.Add(time.Duration(float64(received.Sub(first)) / speed))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Until(at)):
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fn(req)
+	})
+	return err
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"time"
+
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	traces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// payloadSize approximates memory used by a payload with its encoded protobuf size
+func payloadSize(p any) int {
+	size := 0
+	switch p := p.(type) {
+	case []*logs.ResourceLogs:
+		for _, rl := range p {
+			size += proto.Size(rl)
+		}
+	case []*traces.ResourceSpans:
+		for _, rs := range p {
+			size += proto.Size(rs)
+		}
+	case []*metrics.ResourceMetrics:
+		for _, rm := range p {
+			size += proto.Size(rm)
+		}
+	}
+	return size
+}
+
+// addPayload must be called with Storage locked
+func addPayload(p *Payload) {
+	Storage.payloads = append(Storage.payloads, p)
+	Storage.payloadsReceived++
+	Storage.size += p.Size
+}
+
+// evict drops the oldest items until Storage is within its limits.
+// It must be called with Storage locked.
+func evict(now time.Time) {
+	l := Storage.limits
+
+	if l.MaxAge > 0 {
+		cutoff := now.Add(-l.MaxAge)
+		n := 0
+		for n < len(Storage.payloads) && Storage.payloads[n].Received.Before(cutoff) {
+			n++
+		}
+		dropPayloads(n)
+		evictReceivedBefore(cutoff)
+	}
+
+	if l.MaxMemory > 0 && Storage.size > l.MaxMemory {
+		// the data kept by logs and traces is shared with payloads, so dropping a payload
+		// only frees memory once everything received with it is dropped as well
+		var cutoff time.Time
+		n, size := 0, Storage.size
+		for n < len(Storage.payloads) && size > l.MaxMemory {
+			cutoff = Storage.payloads[n].Received
+			size -= Storage.payloads[n].Size
+			n++
+		}
+		dropPayloads(n)
+		evictReceivedBefore(cutoff.Add(time.Nanosecond))
+	}
+
+	if l.MaxPayloads > 0 && len(Storage.payloads) > l.MaxPayloads {
+		dropPayloads(len(Storage.payloads) - l.MaxPayloads)
+	}
+
+	if l.MaxLogs > 0 && len(Storage.logs) > l.MaxLogs {
+		dropFirstLogs(len(Storage.logs) - l.MaxLogs)
+	}
+
+	for l.MaxTraces > 0 && len(Storage.traceOrder) > l.MaxTraces {
+		dropOldestTrace()
+	}
+	for l.MaxSpans > 0 && Storage.spans > l.MaxSpans && len(Storage.traceOrder) > 0 {
+		dropOldestTrace()
+	}
+
+	if l.MaxDatapoints > 0 {
+		for name, dps := range Storage.metrics {
+			if n := len(dps.Times) - l.MaxDatapoints; n > 0 {
+				trimDatapoints(name, dps, n)
+			}
+		}
+	}
+}
+
+func sizeOf(ps []*Payload) int {
+	size := 0
+	for _, p := range ps {
+		size += p.Size
+	}
+	return size
+}
+
+// dropPayloads drops the n oldest payloads
+func dropPayloads(n int) {
+	if n == 0 {
+		return
+	}
+	Storage.size -= sizeOf(Storage.payloads[:n])
+	clear(Storage.payloads[:n])
+	Storage.payloads = Storage.payloads[n:]
+}
+
+// evictReceivedBefore drops logs, traces and datapoints first received before cutoff
+func evictReceivedBefore(cutoff time.Time) {
+	n := 0
+	for n < len(Storage.logOrder) && Storage.logOrder[n].Received.Before(cutoff) {
+		n++
+	}
+	dropFirstLogs(n)
+
+	for len(Storage.traceOrder) > 0 && Storage.traces[Storage.traceOrder[0]].Received.Before(cutoff) {
+		dropOldestTrace()
+	}
+
+	for name, dps := range Storage.metrics {
+		n := 0
+		for n < len(dps.received) && dps.received[n] < cutoff.UnixNano() {
+			n++
+		}
+		trimDatapoints(name, dps, n)
+	}
+}
+
+// dropFirstLogs drops the n logs received first, logs are sorted by timestamp so a late log isn't among them
+func dropFirstLogs(n int) {
+	if n == 0 {
+		return
+	}
+	dropped := make(map[*Log]bool, n)
+	for _, l := range Storage.logOrder[:n] {
+		dropped[l] = true
+	}
+	clear(Storage.logOrder[:n])
+	Storage.logOrder = Storage.logOrder[n:]
+
+	kept := Storage.logs[:0]
+	for _, l := range Storage.logs {
+		if !dropped[l] {
+			kept = append(kept, l)
+		}
+	}
+	clear(Storage.logs[len(kept):])
+	Storage.logs = kept
+	Storage.evicted += n
+}
+
+func dropOldestTrace() {
+	tid := Storage.traceOrder[0]
+	Storage.traceOrder[0] = ""
+	Storage.traceOrder = Storage.traceOrder[1:]
+	n := len(Storage.traces[tid].Spans)
+	Storage.spans -= n
+	Storage.evicted += n
+	delete(Storage.traces, tid)
+}
+
+// trimDatapoints drops the n oldest datapoints of a series, series left empty are removed
+func trimDatapoints(name string, d *Datapoints, n int) {
+	if n == 0 {
+		return
+	}
+	d.Times = d.Times[n:]
+	d.Values = d.Values[n:]
+	d.received = d.received[n:]
+	if d.Histograms != nil {
+		clear(d.Histograms[:n])
+		d.Histograms = d.Histograms[n:]
+	}
+	Storage.evicted += n
+	if len(d.Times) == 0 {
+		delete(Storage.metrics, name)
+	}
+}
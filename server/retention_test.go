@@ -0,0 +1,96 @@
+package server
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// testGauge returns a gauge of datapoints with the given source timestamps and values of the same
+func testGauge(times ...uint64) []*metrics.ResourceMetrics {
+	g := &metrics.Gauge{}
+	for _, ts := range times {
+		g.DataPoints = append(g.DataPoints, &metrics.NumberDataPoint{TimeUnixNano: ts, Value: &metrics.NumberDataPoint_AsInt{AsInt: int64(ts)}})
+	}
+	return []*metrics.ResourceMetrics{{ScopeMetrics: []*metrics.ScopeMetrics{{
+		Metrics: []*metrics.Metric{{Name: "queue_size", Data: &metrics.Metric_Gauge{Gauge: g}}},
+	}}}}
+}
+
+func testLogs(times ...uint64) []*logs.ResourceLogs {
+	sl := &logs.ScopeLogs{}
+	for _, ts := range times {
+		sl.LogRecords = append(sl.LogRecords, &logs.LogRecord{TimeUnixNano: ts, Body: &v1.AnyValue{Value: &v1.AnyValue_IntValue{IntValue: int64(ts)}}})
+	}
+	return []*logs.ResourceLogs{{ScopeLogs: []*logs.ScopeLogs{sl}}}
+}
+
+func storedValues() []float64 {
+	for _, dps := range Storage.metrics {
+		return dps.Values
+	}
+	return nil
+}
+
+func storedLogs() []int64 {
+	var bodies []int64
+	for _, l := range Storage.logs {
+		bodies = append(bodies, l.Log.Body.GetIntValue())
+	}
+	return bodies
+}
+
+func TestEvictDatapointsByReceiveTime(t *testing.T) {
+	now := time.Now()
+	setupStorage(Limits{})
+	tests := []struct {
+		name   string
+		limits Limits
+		want   []float64
+	}{
+		// source timestamps are far in the past and out of order, only when they were received matters
+		{"max age", Limits{MaxAge: time.Minute}, []float64{3}},
+		{"max memory", Limits{MaxMemory: payloadSize(testGauge(3))}, []float64{3}},
+		{"no limits", Limits{}, []float64{5, 1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Reset()
+			Storage.limits = Limits{}
+			consumeMetrics(testGauge(5, 1), now)
+			Storage.limits = tt.limits
+			consumeMetrics(testGauge(3), now.Add(2*time.Minute))
+			if got := storedValues(); !slices.Equal(got, tt.want) {
+				t.Errorf("datapoints %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvictLogsByArrival(t *testing.T) {
+	now := time.Now()
+	setupStorage(Limits{MaxLogs: 3})
+	consumeLogs(testLogs(50, 10), now)
+	consumeLogs(testLogs(30, 20), now.Add(time.Second))
+	// the log received first is dropped, though later ones have older timestamps
+	if got, want := storedLogs(), []int64{10, 20, 30}; !slices.Equal(got, want) {
+		t.Errorf("logs %v, want %v", got, want)
+	}
+	consumeLogs(testLogs(40), now.Add(2*time.Second))
+	if got, want := storedLogs(), []int64{20, 30, 40}; !slices.Equal(got, want) {
+		t.Errorf("logs %v, want %v", got, want)
+	}
+
+	Storage.limits = Limits{MaxAge: time.Minute}
+	evict(now.Add(time.Minute + 1500*time.Millisecond))
+	if got, want := storedLogs(), []int64{40}; !slices.Equal(got, want) {
+		t.Errorf("logs %v, want %v", got, want)
+	}
+	if len(Storage.logOrder) != 1 {
+		t.Errorf("%d logs in arrival order, want 1", len(Storage.logOrder))
+	}
+}
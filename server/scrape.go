@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	colmetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// TransportScrape is scraping Prometheus metrics endpoints
+const TransportScrape = "prometheus/scrape"
+
+// defaultScrapeInterval is used for targets without an interval
+const defaultScrapeInterval = 15 * time.Second
+
+// ScrapeTarget is a Prometheus metrics endpoint to scrape periodically
+type ScrapeTarget struct {
+	URL      string
+	Interval time.Duration
+}
+
+// ParseScrapeTarget parses a target like http://localhost:9090/metrics@5s, the interval is optional
+func ParseScrapeTarget(s string) (ScrapeTarget, error) {
+	t := ScrapeTarget{URL: s, Interval: defaultScrapeInterval}
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		if d, err := time.ParseDuration(s[i+1:]); err == nil {
+			if d <= 0 {
+				return t, fmt.Errorf("scrape interval must be positive")
+			}
+			t.URL, t.Interval = s[:i], d
+		}
+	}
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return t, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return t, fmt.Errorf("scrape target %q must be an http(s) URL", t.URL)
+	}
+	return t, nil
+}
+
+// scrape scrapes a target every interval until ctx is done
+func scrape(ctx context.Context, t ScrapeTarget) {
+	u, _ := url.Parse(t.URL)
+	res := &resource.Resource{Attributes: []*v1.KeyValue{
+		stringAttr("service.name", u.Host),
+		stringAttr("url.full", t.URL),
+	}}
+	scope := &v1.InstrumentationScope{Name: "otelui/scrape"}
+	start := time.Now()
+	client := &http.Client{Timeout: t.Interval}
+
+	tick := time.NewTicker(t.Interval)
+	defer tick.Stop()
+	for {
+		r := &request{transport: TransportScrape, peer: Peer{Addr: u.Host}, start: time.Now()}
+		ms, err := scrapeOnce(ctx, client, r, t.URL, start)
+		up := 1.0
+		if err != nil {
+			slog.WarnContext(ctx, "failed to scrape", "target", t.URL, "err", err)
+			up = 0
+		}
+		// like Prometheus, up tells whether the last scrape succeeded
+		ms = append(ms, &metrics.Metric{Name: "up", Data: &metrics.Metric_Gauge{Gauge: &metrics.Gauge{
+			DataPoints: []*metrics.NumberDataPoint{{
+				TimeUnixNano: uint64(r.start.UnixNano()),
+				Value:        &metrics.NumberDataPoint_AsDouble{AsDouble: up},
+			}},
+		}}})
+		req := &colmetrics.ExportMetricsServiceRequest{ResourceMetrics: []*metrics.ResourceMetrics{{
+			Resource:     res,
+			ScopeMetrics: []*metrics.ScopeMetrics{{Scope: scope, Metrics: ms}},
+		}}}
+		rej := receive(req)
+		if err != nil {
+			observe(r, nil, rej)
+		} else {
+			observe(r, req, rej)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func scrapeOnce(ctx context.Context, client *http.Client, r *request, target string, start time.Time) ([]*metrics.Metric, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s responded with %s", target, res.Status)
+	}
+
+	body, err := readLimited(res.Body)
+	r.bytes = len(body)
+	if err != nil {
+		return nil, err
+	}
+	families, err := parsePrometheus(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return promToOTLP(families, start, time.Now()), nil
+}
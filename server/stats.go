@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltraces "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// Transports requests can be received over
+const (
+	TransportGRPC      = "grpc/protobuf"
+	TransportHTTPProto = "http/protobuf"
+	TransportHTTPJSON  = "http/json"
+)
+
+// ReceiverStats are running totals of the requests received over a transport or from a peer
+type ReceiverStats struct {
+	Requests int
+	// Errors are requests that could not be decoded
+	Errors int
+	// DecodedBytes are of request bodies after decompression, as the size on the wire
+	// isn't known for every transport
+	DecodedBytes int
+	Records      int // logs, spans and datapoints, including rejected ones
+	Rejected     int
+	Latency      time.Duration // total time spent handling requests
+	LastSeen     time.Time
+}
+
+// AvgLatency is the average time it took to handle a request
+func (s ReceiverStats) AvgLatency() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(s.Requests)
+}
+
+// Peer identifies a client exporting telemetry
+type Peer struct {
+	Addr      string // IP address, without the port
+	UserAgent string
+}
+
+// PeerStats are the stats of a peer, with what it told about itself in its resources
+type PeerStats struct {
+	Peer
+	ReceiverStats
+	Transports []string
+	Services   []string // service.name of its resources
+	SDKs       []string // telemetry.sdk.* of its resources, eg. "opentelemetry go 1.38.0"
+}
+
+// maxPeers bounds the number of peers kept, the least recently seen one is forgotten first
+const maxPeers = 256
+
+var stats struct {
+	sync.Mutex
+	transports map[string]*ReceiverStats
+	peers      map[Peer]*PeerStats
+}
+
+// request describes a request for stats, it is filled in while the request is handled
+type request struct {
+	transport string
+	peer      Peer
+	start     time.Time
+	bytes     int
+}
+
+func grpcRequest(ctx context.Context) *request {
+	r := &request{transport: TransportGRPC, start: time.Now()}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.peer.Addr = hostOf(p.Addr.String())
+	}
+	if ua := metadata.ValueFromIncomingContext(ctx, "user-agent"); len(ua) > 0 {
+		r.peer.UserAgent = ua[0]
+	}
+	return r
+}
+
+func httpRequest(req *http.Request, transport string) *request {
+	return &request{
+		transport: transport,
+		peer:      Peer{Addr: hostOf(req.RemoteAddr), UserAgent: req.UserAgent()},
+		start:     time.Now(),
+	}
+}
+
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// observe adds a handled request to the stats, req is nil if it could not be decoded
+func observe(r *request, req proto.Message, rej rejection) {
+	records, services, sdks := describe(req)
+	addStats(r, req != nil, records, services, sdks, rej)
+}
+
+// addStats adds a handled request to the stats, ok is false if it could not be decoded
+func addStats(r *request, ok bool, records int, services, sdks []string, rej rejection) {
+	now := time.Now()
+
+	stats.Lock()
+	defer stats.Unlock()
+	if stats.transports == nil {
+		stats.transports = map[string]*ReceiverStats{}
+		stats.peers = map[Peer]*PeerStats{}
+	}
+	t := stats.transports[r.transport]
+	if t == nil {
+		t = &ReceiverStats{}
+		stats.transports[r.transport] = t
+	}
+	p := stats.peers[r.peer]
+	if p == nil {
+		if len(stats.peers) >= maxPeers {
+			forgetIdlestPeer()
+		}
+		p = &PeerStats{Peer: r.peer}
+		stats.peers[r.peer] = p
+	}
+	p.Transports = addUnique(p.Transports, r.transport)
+	p.Services = addUnique(p.Services, services...)
+	p.SDKs = addUnique(p.SDKs, sdks...)
+
+	for _, s := range []*ReceiverStats{t, &p.ReceiverStats} {
+		s.Requests++
+		if !ok {
+			s.Errors++
+		}
+		s.DecodedBytes += r.bytes
+		s.Records += records
+		s.Rejected += rej.count
+		s.Latency += now.Sub(r.start)
+		s.LastSeen = now
+	}
+}
+
+// forgetIdlestPeer drops the least recently seen peer, it must be called with stats locked
+func forgetIdlestPeer() {
+	var idlest *PeerStats
+	for _, p := range stats.peers {
+		if idlest == nil || p.LastSeen.Before(idlest.LastSeen) {
+			idlest = p
+		}
+	}
+	if idlest != nil {
+		delete(stats.peers, idlest.Peer)
+	}
+}
+
+// describe counts the records of a request, and collects the services and SDKs that sent them
+func describe(req proto.Message) (records int, services, sdks []string) {
+	var resources []*resource.Resource
+	switch req := req.(type) {
+	case *collogs.ExportLogsServiceRequest:
+		for _, rl := range req.ResourceLogs {
+			resources = append(resources, rl.Resource)
+			for _, sl := range rl.ScopeLogs {
+				records += len(sl.LogRecords)
+			}
+		}
+	case *coltraces.ExportTraceServiceRequest:
+		for _, rs := range req.ResourceSpans {
+			resources = append(resources, rs.Resource)
+			for _, ss := range rs.ScopeSpans {
+				records += len(ss.Spans)
+			}
+		}
+	case *colmetrics.ExportMetricsServiceRequest:
+		for _, rm := range req.ResourceMetrics {
+			resources = append(resources, rm.Resource)
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					records += datapointCount(m)
+				}
+			}
+		}
+	}
+
+	for _, r := range resources {
+		var name, lang, version string
+		for _, kv := range r.GetAttributes() {
+			switch kv.Key {
+			case "service.name":
+				services = addUnique(services, stringValue(kv.Value))
+			case "telemetry.sdk.name":
+				name = stringValue(kv.Value)
+			case "telemetry.sdk.language":
+				lang = stringValue(kv.Value)
+			case "telemetry.sdk.version":
+				version = stringValue(kv.Value)
+			}
+		}
+		if sdk := strings.Join(strings.Fields(name+" "+lang+" "+version), " "); sdk != "" {
+			sdks = addUnique(sdks, sdk)
+		}
+	}
+	return records, services, sdks
+}
+
+func stringValue(v *v1.AnyValue) string { return v.GetStringValue() }
+
+// addUnique adds values missing from a sorted list
+func addUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		i := sort.SearchStrings(list, v)
+		if i < len(list) && list[i] == v {
+			continue
+		}
+		list = append(list, "")
+		copy(list[i+1:], list[i:])
+		list[i] = v
+	}
+	return list
+}
+
+// GetReceiverStats returns the stats of every transport and peer that sent a request
+func GetReceiverStats() (map[string]ReceiverStats, []PeerStats) {
+	stats.Lock()
+	defer stats.Unlock()
+	transports := make(map[string]ReceiverStats, len(stats.transports))
+	for k, v := range stats.transports {
+		transports[k] = *v
+	}
+	peers := make([]PeerStats, 0, len(stats.peers))
+	for _, p := range stats.peers {
+		c := *p
+		c.Transports = append([]string(nil), p.Transports...)
+		c.Services = append([]string(nil), p.Services...)
+		c.SDKs = append([]string(nil), p.SDKs...)
+		peers = append(peers, c)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].Addr != peers[j].Addr {
+			return peers[i].Addr < peers[j].Addr
+		}
+		return peers[i].UserAgent < peers[j].UserAgent
+	})
+	return transports, peers
+}
+
+func resetStats() {
+	stats.Lock()
+	defer stats.Unlock()
+	stats.transports = nil
+	stats.peers = nil
+}
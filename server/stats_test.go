@@ -0,0 +1,33 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAddStatsForgetsIdlestPeer(t *testing.T) {
+	resetStats()
+	defer resetStats()
+	for i := range maxPeers {
+		addStats(&request{transport: TransportHTTPProto, peer: Peer{Addr: fmt.Sprint(i)}, start: time.Now(), bytes: 10}, true, 1, nil, nil, rejection{})
+	}
+	// peer 1 is now the least recently seen one
+	addStats(&request{transport: TransportHTTPProto, peer: Peer{Addr: "0"}, start: time.Now()}, true, 1, nil, nil, rejection{})
+	addStats(&request{transport: TransportHTTPProto, peer: Peer{Addr: "new"}, start: time.Now()}, true, 1, nil, nil, rejection{})
+
+	transports, peers := GetReceiverStats()
+	if len(peers) != maxPeers {
+		t.Fatalf("%d peers, want %d", len(peers), maxPeers)
+	}
+	seen := map[string]bool{}
+	for _, p := range peers {
+		seen[p.Addr] = true
+	}
+	if !seen["0"] || !seen["new"] || seen["1"] {
+		t.Errorf("peer 1 kept, want it forgotten as the least recently seen one")
+	}
+	if s := transports[TransportHTTPProto]; s.Requests != maxPeers+2 || s.DecodedBytes != 10*maxPeers {
+		t.Errorf("transport stats %+v", s)
+	}
+}
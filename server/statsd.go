@@ -0,0 +1,317 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	colmetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// TransportStatsD is StatsD and DogStatsD over UDP
+const TransportStatsD = "udp/statsd"
+
+// defaultStatsDFlush is how often aggregated StatsD metrics are stored if not configured
+const defaultStatsDFlush = 10 * time.Second
+
+// statsdIdleFlushes is after how many flushes without updates a series is forgotten, along with its gauge value
+const statsdIdleFlushes = 5
+
+// statsdBounds are the explicit bounds of timer and histogram buckets, the SDK defaults
+var statsdBounds = []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000}
+
+// statsdLine is a parsed StatsD line, eg. "api.requests:1|c|@0.5|#env:prod"
+type statsdLine struct {
+	name   string
+	typ    string // c, g, ms, h, d or s
+	values []string
+	rate   float64
+	tags   []promLabel
+}
+
+// statsdSeries aggregates the lines of a metric with the same name, type and tags between flushes
+type statsdSeries struct {
+	name  string
+	typ   string
+	attrs []*v1.KeyValue
+
+	updated bool
+	idle    int     // flushes since the last update
+	value   float64 // counter sum, or gauge value which is kept across flushes while the series is active
+	set     map[string]struct{}
+
+	count   float64
+	sum     float64
+	min     float64
+	max     float64
+	buckets []uint64
+}
+
+// statsdAggregator holds the series received since the last flush
+type statsdAggregator struct {
+	sync.Mutex
+	series map[string]*statsdSeries
+	order  []string
+	start  time.Time
+}
+
+// listenStatsD receives StatsD datagrams on pc until ctx is done, storing them every flush interval
+func listenStatsD(ctx context.Context, pc net.PacketConn, flush time.Duration) {
+	agg := &statsdAggregator{series: map[string]*statsdSeries{}, start: time.Now()}
+
+	go func() {
+		tick := time.NewTicker(flush)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				pc.Close()
+				return
+			case now := <-tick.C:
+				if req := agg.flush(now); req != nil {
+					receive(req)
+				}
+			}
+		}
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.ErrorContext(ctx, "StatsD receiver read error", "err", err)
+			}
+			return
+		}
+		r := &request{transport: TransportStatsD, peer: Peer{Addr: hostOf(addr.String())}, start: time.Now(), bytes: n}
+		records, rej := agg.add(buf[:n])
+		if rej.count > 0 {
+			Storage.Lock()
+			Storage.rejected.Datapoints += rej.count
+			Storage.Unlock()
+		}
+		addStats(r, records > 0 || rej.count == 0, records, nil, nil, rej)
+	}
+}
+
+// add aggregates the lines of a datagram, returning how many were valid and why others were not
+func (a *statsdAggregator) add(b []byte) (records int, rej rejection) {
+	a.Lock()
+	defer a.Unlock()
+	for _, raw := range bytes.Split(b, []byte("\n")) {
+		line := strings.TrimSpace(string(raw))
+		if line == "" || strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+			// DogStatsD events and service checks are not metrics
+			continue
+		}
+		l, err := parseStatsD(line)
+		if err != nil {
+			rej.add(err)
+			continue
+		}
+		if err := a.addLine(l); err != nil {
+			rej.add(err)
+			continue
+		}
+		records++
+	}
+	return records, rej
+}
+
+// addLine aggregates a line into its series, the line is rejected as a whole if any value is invalid
+func (a *statsdAggregator) addLine(l statsdLine) error {
+	var values []float64
+	if l.typ != "s" {
+		values = make([]float64, len(l.values))
+		for i, v := range l.values {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+				return fmt.Errorf("invalid value %q of %s", v, l.name)
+			}
+			values[i] = f
+		}
+	}
+
+	attrs := promAttributes(l.tags, "")
+	key := l.typ + "|" + serializeAttributes(l.name, attrs)
+	s, ok := a.series[key]
+	if !ok {
+		s = &statsdSeries{name: l.name, typ: l.typ, attrs: attrs}
+		a.series[key] = s
+		a.order = append(a.order, key)
+	}
+	s.updated = true
+
+	if l.typ == "s" {
+		if s.set == nil {
+			s.set = map[string]struct{}{}
+		}
+		for _, v := range l.values {
+			s.set[v] = struct{}{}
+		}
+		return nil
+	}
+	for i, f := range values {
+		switch l.typ {
+		case "c":
+			s.value += f / l.rate
+		case "g":
+			// as in Etsy's statsd, a signed value changes the gauge, so a gauge can only be set
+			// to a negative value by setting it to 0 first, and -5 is a decrement even for a new one
+			if v := l.values[i]; v[0] == '+' || v[0] == '-' {
+				s.value += f
+			} else {
+				s.value = f
+			}
+		default:
+			weight := 1 / l.rate
+			if s.count == 0 {
+				s.min, s.max = f, f
+				s.buckets = make([]uint64, len(statsdBounds)+1)
+			}
+			s.count += weight
+			s.sum += f * weight
+			s.min = min(s.min, f)
+			s.max = max(s.max, f)
+			s.buckets[sort.SearchFloat64s(statsdBounds, f)] += uint64(math.Round(weight))
+		}
+	}
+	return nil
+}
+
+// flush turns the series updated since the last flush into metrics and resets them, nil if there were none.
+// Series idle for statsdIdleFlushes are removed.
+func (a *statsdAggregator) flush(now time.Time) *colmetrics.ExportMetricsServiceRequest {
+	a.Lock()
+	defer a.Unlock()
+
+	start, end := uint64(a.start.UnixNano()), uint64(now.UnixNano())
+	a.start = now
+	var ms []*metrics.Metric
+	order := a.order[:0]
+	for _, key := range a.order {
+		s := a.series[key]
+		if !s.updated {
+			if s.idle++; s.idle >= statsdIdleFlushes {
+				delete(a.series, key)
+			} else {
+				order = append(order, key)
+			}
+			continue
+		}
+		order = append(order, key)
+		s.idle = 0
+		switch s.typ {
+		case "c":
+			ms = append(ms, &metrics.Metric{Name: s.name, Data: &metrics.Metric_Sum{Sum: &metrics.Sum{
+				IsMonotonic:            true,
+				AggregationTemporality: metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+				DataPoints: []*metrics.NumberDataPoint{{
+					Attributes: s.attrs, StartTimeUnixNano: start, TimeUnixNano: end,
+					Value: &metrics.NumberDataPoint_AsDouble{AsDouble: s.value},
+				}},
+			}}})
+			s.value = 0
+		case "g":
+			ms = append(ms, &metrics.Metric{Name: s.name, Data: &metrics.Metric_Gauge{Gauge: &metrics.Gauge{
+				DataPoints: []*metrics.NumberDataPoint{{
+					Attributes: s.attrs, TimeUnixNano: end,
+					Value: &metrics.NumberDataPoint_AsDouble{AsDouble: s.value},
+				}},
+			}}})
+		case "s":
+			ms = append(ms, &metrics.Metric{Name: s.name, Data: &metrics.Metric_Gauge{Gauge: &metrics.Gauge{
+				DataPoints: []*metrics.NumberDataPoint{{
+					Attributes: s.attrs, StartTimeUnixNano: start, TimeUnixNano: end,
+					Value: &metrics.NumberDataPoint_AsInt{AsInt: int64(len(s.set))},
+				}},
+			}}})
+			s.set = nil
+		default:
+			sum, minimum, maximum := s.sum, s.min, s.max
+			// bucket counts are rounded from sampled values, so the count has to match them
+			var count uint64
+			for _, c := range s.buckets {
+				count += c
+			}
+			unit := ""
+			if s.typ == "ms" {
+				unit = "ms"
+			}
+			ms = append(ms, &metrics.Metric{Name: s.name, Unit: unit, Data: &metrics.Metric_Histogram{Histogram: &metrics.Histogram{
+				AggregationTemporality: metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+				DataPoints: []*metrics.HistogramDataPoint{{
+					Attributes: s.attrs, StartTimeUnixNano: start, TimeUnixNano: end,
+					Count: count, Sum: &sum, Min: &minimum, Max: &maximum,
+					BucketCounts: s.buckets, ExplicitBounds: statsdBounds,
+				}},
+			}}})
+			s.count, s.sum, s.buckets = 0, 0, nil
+		}
+		s.updated = false
+	}
+	clear(a.order[len(order):])
+	a.order = order
+	if len(ms) == 0 {
+		return nil
+	}
+	return &colmetrics.ExportMetricsServiceRequest{ResourceMetrics: []*metrics.ResourceMetrics{{
+		Resource:     &resource.Resource{},
+		ScopeMetrics: []*metrics.ScopeMetrics{{Scope: &v1.InstrumentationScope{Name: "otelui/statsd"}, Metrics: ms}},
+	}}}
+}
+
+// parseStatsD parses a StatsD line with the DogStatsD extensions: several values separated by colons,
+// a sample rate like |@0.1 and tags like |#env:prod,canary
+func parseStatsD(line string) (statsdLine, error) {
+	l := statsdLine{rate: 1}
+	parts := strings.Split(line, "|")
+	if len(parts) < 2 {
+		return l, fmt.Errorf("invalid statsd line %q: missing type", line)
+	}
+	name, values, ok := strings.Cut(parts[0], ":")
+	if !ok || name == "" || values == "" {
+		return l, fmt.Errorf("invalid statsd line %q: missing value", line)
+	}
+	l.name, l.values = name, strings.Split(values, ":")
+
+	l.typ = parts[1]
+	switch l.typ {
+	case "c", "g", "ms", "h", "d", "s":
+	default:
+		return l, fmt.Errorf("invalid statsd line %q: unknown type %q", line, l.typ)
+	}
+
+	for _, p := range parts[2:] {
+		switch {
+		case strings.HasPrefix(p, "@"):
+			rate, err := strconv.ParseFloat(p[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return l, fmt.Errorf("invalid statsd line %q: invalid sample rate", line)
+			}
+			l.rate = rate
+		case strings.HasPrefix(p, "#"):
+			for _, tag := range strings.Split(p[1:], ",") {
+				if tag == "" {
+					continue
+				}
+				k, v, _ := strings.Cut(tag, ":")
+				l.tags = append(l.tags, promLabel{k, v})
+			}
+		}
+	}
+	return l, nil
+}
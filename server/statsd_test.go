@@ -0,0 +1,207 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func TestParseStatsD(t *testing.T) {
+	tests := []struct {
+		line string
+		want statsdLine
+		err  string
+	}{
+		{line: "api.requests:1|c", want: statsdLine{name: "api.requests", typ: "c", values: []string{"1"}, rate: 1}},
+		{line: "temp:-3.5|g", want: statsdLine{name: "temp", typ: "g", values: []string{"-3.5"}, rate: 1}},
+		{line: "latency:12|ms|@0.1", want: statsdLine{name: "latency", typ: "ms", values: []string{"12"}, rate: 0.1}},
+		{line: "size:1:2:3|h", want: statsdLine{name: "size", typ: "h", values: []string{"1", "2", "3"}, rate: 1}},
+		{line: "users:alice|s", want: statsdLine{name: "users", typ: "s", values: []string{"alice"}, rate: 1}},
+		{
+			line: "api.requests:1|c|@0.5|#env:prod,canary",
+			want: statsdLine{name: "api.requests", typ: "c", values: []string{"1"}, rate: 0.5,
+				tags: []promLabel{{"env", "prod"}, {"canary", ""}}},
+		},
+		{
+			line: "payload:512|d|#region:eu,,az:a:1",
+			want: statsdLine{name: "payload", typ: "d", values: []string{"512"}, rate: 1,
+				tags: []promLabel{{"region", "eu"}, {"az", "a:1"}}},
+		},
+		{line: "api.requests:1|c|c:abc", want: statsdLine{name: "api.requests", typ: "c", values: []string{"1"}, rate: 1}},
+		{line: "api.requests", err: "missing type"},
+		{line: "api.requests|c", err: "missing value"},
+		{line: ":1|c", err: "missing value"},
+		{line: "api.requests:|c", err: "missing value"},
+		{line: "api.requests:1|x", err: `unknown type "x"`},
+		{line: "api.requests:1|c|@0", err: "invalid sample rate"},
+		{line: "api.requests:1|c|@2", err: "invalid sample rate"},
+		{line: "api.requests:1|c|@half", err: "invalid sample rate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parseStatsD(tt.line)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseStatsD(%q) error %v, want %q", tt.line, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseStatsD(%q) error: %v", tt.line, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStatsD(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsDAggregator(t *testing.T) {
+	tests := []struct {
+		name     string
+		datagram string
+		records  int
+		rejected int
+		check    func(*testing.T, *metrics.Metric)
+	}{
+		{
+			name:     "sampled counter",
+			datagram: "hits:1|c|@0.5\nhits:2|c",
+			records:  2,
+			check: func(t *testing.T, m *metrics.Metric) {
+				if v := m.GetSum().DataPoints[0].GetAsDouble(); v != 4 {
+					t.Errorf("sum %v, want 4", v)
+				}
+			},
+		},
+		{
+			name:     "gauge deltas",
+			datagram: "temp:10|g\ntemp:+5|g\ntemp:-2|g",
+			records:  3,
+			check: func(t *testing.T, m *metrics.Metric) {
+				if v := m.GetGauge().DataPoints[0].GetAsDouble(); v != 13 {
+					t.Errorf("gauge %v, want 13", v)
+				}
+			},
+		},
+		{
+			name:     "negative gauge",
+			datagram: "temp:-5|g",
+			records:  1,
+			check: func(t *testing.T, m *metrics.Metric) {
+				if v := m.GetGauge().DataPoints[0].GetAsDouble(); v != -5 {
+					t.Errorf("gauge %v, want -5 decremented from 0", v)
+				}
+			},
+		},
+		{
+			name:     "gauge set negative",
+			datagram: "temp:10|g\ntemp:0|g\ntemp:-5|g",
+			records:  3,
+			check: func(t *testing.T, m *metrics.Metric) {
+				if v := m.GetGauge().DataPoints[0].GetAsDouble(); v != -5 {
+					t.Errorf("gauge %v, want -5", v)
+				}
+			},
+		},
+		{
+			name:     "set",
+			datagram: "users:alice|s\nusers:bob|s\nusers:alice|s",
+			records:  3,
+			check: func(t *testing.T, m *metrics.Metric) {
+				if v := m.GetGauge().DataPoints[0].GetAsInt(); v != 2 {
+					t.Errorf("unique values %v, want 2", v)
+				}
+			},
+		},
+		{
+			name:     "sampled timer",
+			datagram: "latency:3:30|ms|@0.5",
+			records:  1,
+			check: func(t *testing.T, m *metrics.Metric) {
+				dp := m.GetHistogram().DataPoints[0]
+				if m.Unit != "ms" || dp.Count != 4 || dp.GetSum() != 66 || dp.GetMin() != 3 || dp.GetMax() != 30 {
+					t.Errorf("histogram %s %v, want ms count 4 sum 66 min 3 max 30", m.Unit, dp)
+				}
+				if dp.BucketCounts[1] != 2 || dp.BucketCounts[4] != 2 {
+					t.Errorf("buckets %v, want 2 in (0, 5] and (25, 50]", dp.BucketCounts)
+				}
+			},
+		},
+		{
+			name:     "tags",
+			datagram: "hits:1|c|#env:prod,canary\n_e{5,4}:title|text\n_sc|db|0",
+			records:  1,
+			check: func(t *testing.T, m *metrics.Metric) {
+				attrs := m.GetSum().DataPoints[0].Attributes
+				if len(attrs) != 2 || attrs[0].Key != "env" || attrs[0].Value.GetStringValue() != "prod" || attrs[1].Key != "canary" {
+					t.Errorf("attributes %v, want env=prod and canary", attrs)
+				}
+			},
+		},
+		{
+			name:     "invalid lines",
+			datagram: "hits:1|c\nhits|c\ntemp:hot|g\ntemp:NaN|g\nhits:1:bad|c",
+			records:  1,
+			rejected: 4,
+			check: func(t *testing.T, m *metrics.Metric) {
+				if v := m.GetSum().DataPoints[0].GetAsDouble(); v != 1 {
+					t.Errorf("sum %v, want 1 without the valid value of a rejected line", v)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &statsdAggregator{series: map[string]*statsdSeries{}, start: time.Now()}
+			records, rej := a.add([]byte(tt.datagram))
+			if records != tt.records || rej.count != tt.rejected {
+				t.Fatalf("add() = %d records and %d rejected, want %d and %d", records, rej.count, tt.records, tt.rejected)
+			}
+			req := a.flush(time.Now())
+			ms := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+			if len(ms) != 1 {
+				t.Fatalf("flush() = %d metrics, want 1", len(ms))
+			}
+			tt.check(t, ms[0])
+		})
+	}
+}
+
+func TestStatsDAggregatorRejectedLine(t *testing.T) {
+	a := &statsdAggregator{series: map[string]*statsdSeries{}, start: time.Now()}
+	if records, rej := a.add([]byte("x:1:bad|c\ntemp:hot|g")); records != 0 || rej.count != 2 {
+		t.Fatalf("add() = %d records and %d rejected, want 0 and 2", records, rej.count)
+	}
+	if len(a.series) != 0 || len(a.order) != 0 {
+		t.Errorf("%d series left by rejected lines", len(a.series))
+	}
+	if req := a.flush(time.Now()); req != nil {
+		t.Errorf("flush() = %v, want nil", req)
+	}
+}
+
+func TestStatsDAggregatorIdle(t *testing.T) {
+	a := &statsdAggregator{series: map[string]*statsdSeries{}, start: time.Now()}
+	a.add([]byte("temp:5|g\nhits:1|c"))
+	a.flush(time.Now())
+	for range statsdIdleFlushes {
+		a.add([]byte("hits:1|c"))
+		a.flush(time.Now())
+	}
+	if len(a.series) != 1 || len(a.order) != 1 || !strings.HasPrefix(a.order[0], "c|") {
+		t.Fatalf("series %v after the gauge was idle, want only the counter", a.order)
+	}
+	if req := a.flush(time.Now()); req != nil {
+		t.Errorf("flush() without updates = %v, want nil", req)
+	}
+
+	a.add([]byte("temp:+1|g"))
+	ms := a.flush(time.Now()).ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if v := ms[0].GetGauge().DataPoints[0].GetAsDouble(); v != 1 {
+		t.Errorf("gauge %v after it was forgotten, want 1", v)
+	}
+}
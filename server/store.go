@@ -20,6 +20,7 @@ import (
 type Payload struct {
 	Received time.Time
 	Num      int
+	Size     int
 	Payload  any
 }
 
@@ -33,6 +34,10 @@ type Log struct {
 type Datapoints struct {
 	Times  []uint64
 	Values []float64
+	// Histograms is set for histogram series, with Values holding their sums
+	Histograms []*Histogram
+	// received holds when each datapoint was received in unix nanoseconds, as Times are set by the source
+	received []int64
 }
 
 type Span struct {
@@ -42,77 +47,127 @@ type Span struct {
 }
 
 type Trace struct {
-	TraceID string
-	Spans   []*Span
+	TraceID  string
+	Received time.Time
+	Spans    []*Span
+}
+
+// Limits bounds the amount of retained telemetry, zero means unlimited.
+// Oldest items are evicted first once a limit is reached.
+type Limits struct {
+	MaxPayloads   int
+	MaxLogs       int
+	MaxTraces     int
+	MaxSpans      int
+	MaxDatapoints int // per metric series
+	MaxAge        time.Duration
+	MaxMemory     int // approximate, in bytes of received protobuf
 }
 
 var Storage struct {
 	sync.RWMutex
 
-	spansReceived   int
-	metricsReceived int
+	limits Limits
+
+	payloadsReceived int
+	logsReceived     int
+	spansReceived    int
+	metricsReceived  int
+	evicted          int
+	rejected         Rejected
+
+	spans int
+	size  int
 
 	payloads   []*Payload
-	logs       []*Log
+	logs       []*Log // sorted by timestamp
+	logOrder   []*Log // in the order received
 	metrics    map[string]*Datapoints
 	traces     map[string]*Trace
 	traceOrder []string
 }
 
+// ConsumeEvent is sent periodically with running totals of received, evicted, rejected and forwarded items
 type ConsumeEvent struct {
 	Payloads int
 	Logs     int
 	Spans    int
 	Metrics  int
+	Evicted  int
+	Rejected Rejected
+	Forward  ForwardStats
 }
 
+// Send receives ConsumeEvents, it is left nil when there is no UI
 var Send func(msg any)
 
 func Reset() {
+	truncateWAL()
+	resetStats()
+	resetForwardStats()
+
 	Storage.Lock()
 	defer Storage.Unlock()
 	Storage.logs = []*Log{}
+	Storage.logOrder = []*Log{}
 	Storage.payloads = []*Payload{}
 	Storage.metrics = map[string]*Datapoints{}
 	Storage.traces = map[string]*Trace{}
 	Storage.traceOrder = []string{}
+	Storage.payloadsReceived = 0
+	Storage.logsReceived = 0
 	Storage.spansReceived = 0
 	Storage.metricsReceived = 0
+	Storage.evicted = 0
+	Storage.rejected = Rejected{}
+	Storage.spans = 0
+	Storage.size = 0
 }
 
-func setupStorage() {
+func setupStorage(limits Limits) {
+	Storage.limits = limits
 	Storage.logs = []*Log{}
+	Storage.logOrder = []*Log{}
 	Storage.payloads = []*Payload{}
 	Storage.metrics = map[string]*Datapoints{}
 	Storage.traces = map[string]*Trace{}
 	Storage.traceOrder = []string{}
 
 	go func() {
-		for range time.Tick(time.Second) {
-			Storage.RLock()
+		for now := range time.Tick(time.Second) {
+			Storage.Lock()
+			evict(now.UTC())
 			e := ConsumeEvent{
-				Payloads: len(Storage.payloads),
-				Logs:     len(Storage.logs),
+				Payloads: Storage.payloadsReceived,
+				Logs:     Storage.logsReceived,
 				Spans:    Storage.spansReceived,
 				Metrics:  Storage.metricsReceived,
+				Evicted:  Storage.evicted,
+				Rejected: Storage.rejected,
+			}
+			Storage.Unlock()
+			e.Forward = GetForwardStats()
+			if Send != nil {
+				Send(e)
 			}
-			Storage.RUnlock()
-			Send(e)
 		}
 	}()
 }
 
-func consumeLogs(p []*logs.ResourceLogs) {
+func consumeLogs(p []*logs.ResourceLogs, now time.Time) (rej rejection) {
 	if p == nil {
-		return
+		return rej
 	}
 
 	newLogs := []*Log{}
-	now := time.Now().UTC()
 
 	for _, rl := range p {
 		for _, sl := range rl.ScopeLogs {
 			for _, l := range sl.LogRecords {
+				if err := validateLog(l); err != nil {
+					rej.add(err)
+					continue
+				}
 				newLogs = append(newLogs, &Log{
 					Log:          l,
 					ResourceLogs: rl,
@@ -126,27 +181,35 @@ func consumeLogs(p []*logs.ResourceLogs) {
 	Storage.Lock()
 	defer Storage.Unlock()
 
-	Storage.payloads = append(Storage.payloads, &Payload{Received: now, Num: len(newLogs), Payload: p})
+	addPayload(&Payload{Received: now, Num: len(newLogs), Size: payloadSize(p), Payload: p})
 	for _, log := range newLogs {
 		i := sort.Search(len(Storage.logs), func(i int) bool { return Storage.logs[i].Log.TimeUnixNano > log.Log.TimeUnixNano })
 		Storage.logs = append(Storage.logs, nil)
 		copy(Storage.logs[i+1:], Storage.logs[i:])
 		Storage.logs[i] = log
 	}
+	Storage.logOrder = append(Storage.logOrder, newLogs...)
+	Storage.logsReceived += len(newLogs)
+	Storage.rejected.Logs += rej.count
+	evict(now)
+	return rej
 }
 
-func consumeTraces(p []*traces.ResourceSpans) {
+func consumeTraces(p []*traces.ResourceSpans, now time.Time) (rej rejection) {
 	if p == nil {
-		return
+		return rej
 	}
 
-	now := time.Now().UTC()
 	spansReceived := 0
 	byTrace := map[string][]*Span{}
 
 	for _, rs := range p {
 		for _, ss := range rs.ScopeSpans {
 			for _, s := range ss.Spans {
+				if err := validateSpan(s); err != nil {
+					rej.add(err)
+					continue
+				}
 				spansReceived++
 				tid := hex.EncodeToString(s.TraceId)
 				byTrace[tid] = append(byTrace[tid], &Span{Span: s, Resource: rs.Resource, Scope: ss.Scope})
@@ -157,24 +220,27 @@ func consumeTraces(p []*traces.ResourceSpans) {
 	Storage.Lock()
 	defer Storage.Unlock()
 
-	Storage.payloads = append(Storage.payloads, &Payload{Received: now, Num: spansReceived, Payload: p})
+	addPayload(&Payload{Received: now, Num: spansReceived, Size: payloadSize(p), Payload: p})
 	for tid, spans := range byTrace {
 		if t, ok := Storage.traces[tid]; ok {
 			t.Spans = append(t.Spans, spans...)
 		} else {
-			Storage.traces[tid] = &Trace{TraceID: tid, Spans: spans}
+			Storage.traces[tid] = &Trace{TraceID: tid, Received: now, Spans: spans}
 			Storage.traceOrder = append(Storage.traceOrder, tid)
 		}
 	}
+	Storage.spans += spansReceived
 	Storage.spansReceived += spansReceived
+	Storage.rejected.Spans += rej.count
+	evict(now)
+	return rej
 }
 
-func consumeMetrics(p []*metrics.ResourceMetrics) {
+func consumeMetrics(p []*metrics.ResourceMetrics, now time.Time) (rej rejection) {
 	if p == nil {
-		return
+		return rej
 	}
 
-	now := time.Now().UTC()
 	metricsReceived := 0
 
 	Storage.Lock()
@@ -183,58 +249,97 @@ func consumeMetrics(p []*metrics.ResourceMetrics) {
 	for _, rm := range p {
 		for _, sm := range rm.ScopeMetrics {
 			for _, m := range sm.Metrics {
+				if err := validateMetric(m); err != nil {
+					for range datapointCount(m) {
+						rej.add(err)
+					}
+					continue
+				}
 				switch d := m.Data.(type) {
 				case *metrics.Metric_Gauge:
 					for _, dp := range d.Gauge.DataPoints {
-						metricsReceived++
-						attrs := serializeAttributes(m.Name, dp.Attributes, sm.Scope.Attributes, rm.Resource.Attributes)
-						if Storage.metrics[attrs] == nil {
-							Storage.metrics[attrs] = &Datapoints{}
-						}
-						Storage.metrics[attrs].Times = append(Storage.metrics[attrs].Times, dp.TimeUnixNano)
-
-						switch v := dp.Value.(type) {
-						case *metrics.NumberDataPoint_AsInt:
-							Storage.metrics[attrs].Values = append(Storage.metrics[attrs].Values, float64(v.AsInt))
-						case *metrics.NumberDataPoint_AsDouble:
-							Storage.metrics[attrs].Values = append(Storage.metrics[attrs].Values, v.AsDouble)
+						if err := validateNumber(dp); err != nil {
+							rej.add(err)
+							continue
 						}
+						metricsReceived++
+						attrs := serializeAttributes(m.Name, dp.Attributes, sm.GetScope().GetAttributes(), rm.GetResource().GetAttributes())
+						appendDatapoint(attrs, now, dp.TimeUnixNano, numberValue(dp))
 					}
 				case *metrics.Metric_Sum:
 					for _, dp := range d.Sum.DataPoints {
-						metricsReceived++
-						attrs := serializeAttributes(m.Name, dp.Attributes, sm.Scope.Attributes, rm.Resource.Attributes)
-						if Storage.metrics[attrs] == nil {
-							Storage.metrics[attrs] = &Datapoints{}
-						}
-						Storage.metrics[attrs].Times = append(Storage.metrics[attrs].Times, dp.TimeUnixNano)
-
-						switch v := dp.Value.(type) {
-						case *metrics.NumberDataPoint_AsInt:
-							Storage.metrics[attrs].Values = append(Storage.metrics[attrs].Values, float64(v.AsInt))
-						case *metrics.NumberDataPoint_AsDouble:
-							Storage.metrics[attrs].Values = append(Storage.metrics[attrs].Values, v.AsDouble)
+						if err := validateNumber(dp); err != nil {
+							rej.add(err)
+							continue
 						}
+						metricsReceived++
+						attrs := serializeAttributes(m.Name, dp.Attributes, sm.GetScope().GetAttributes(), rm.GetResource().GetAttributes())
+						appendDatapoint(attrs, now, dp.TimeUnixNano, numberValue(dp))
 					}
 				case *metrics.Metric_Summary:
 					for _, dp := range d.Summary.DataPoints {
 						metricsReceived++
-						attrs := serializeAttributes(m.Name, dp.Attributes, sm.Scope.Attributes, rm.Resource.Attributes)
-						if Storage.metrics[attrs] == nil {
-							Storage.metrics[attrs] = &Datapoints{}
-						}
-						Storage.metrics[attrs].Times = append(Storage.metrics[attrs].Times, dp.TimeUnixNano)
-						Storage.metrics[attrs].Values = append(Storage.metrics[attrs].Values, dp.Sum)
+						attrs := serializeAttributes(m.Name, dp.Attributes, sm.GetScope().GetAttributes(), rm.GetResource().GetAttributes())
+						appendDatapoint(attrs, now, dp.TimeUnixNano, dp.Sum)
 					}
 				case *metrics.Metric_Histogram:
+					cumulative := d.Histogram.AggregationTemporality == metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+					for _, dp := range d.Histogram.DataPoints {
+						if err := validateHistogram(dp); err != nil {
+							rej.add(err)
+							continue
+						}
+						metricsReceived++
+						attrs := serializeAttributes(m.Name, dp.Attributes, sm.GetScope().GetAttributes(), rm.GetResource().GetAttributes())
+						appendHistogram(attrs, now, dp.TimeUnixNano, explicitHistogram(dp, cumulative))
+					}
 				case *metrics.Metric_ExponentialHistogram:
+					cumulative := d.ExponentialHistogram.AggregationTemporality == metrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+					for _, dp := range d.ExponentialHistogram.DataPoints {
+						if err := validateExponentialHistogram(dp); err != nil {
+							rej.add(err)
+							continue
+						}
+						metricsReceived++
+						attrs := serializeAttributes(m.Name, dp.Attributes, sm.GetScope().GetAttributes(), rm.GetResource().GetAttributes())
+						appendHistogram(attrs, now, dp.TimeUnixNano, exponentialHistogram(dp, cumulative))
+					}
 				}
 			}
 		}
 	}
 
-	Storage.payloads = append(Storage.payloads, &Payload{Received: now, Num: metricsReceived, Payload: p})
+	addPayload(&Payload{Received: now, Num: metricsReceived, Size: payloadSize(p), Payload: p})
 	Storage.metricsReceived += metricsReceived
+	Storage.rejected.Datapoints += rej.count
+	evict(now)
+	return rej
+}
+
+// appendDatapoint must be called with Storage locked
+func appendDatapoint(attrs string, received time.Time, ts uint64, v float64) *Datapoints {
+	dps := Storage.metrics[attrs]
+	if dps == nil {
+		dps = &Datapoints{}
+		Storage.metrics[attrs] = dps
+	}
+	dps.Times = append(dps.Times, ts)
+	dps.Values = append(dps.Values, v)
+	dps.received = append(dps.received, received.UnixNano())
+	return dps
+}
+
+// appendHistogram must be called with Storage locked
+func appendHistogram(attrs string, received time.Time, ts uint64, h *Histogram) {
+	dps := appendDatapoint(attrs, received, ts, h.Sum)
+	dps.Histograms = append(dps.Histograms, h)
+}
+
+func numberValue(dp *metrics.NumberDataPoint) float64 {
+	if v, ok := dp.Value.(*metrics.NumberDataPoint_AsInt); ok {
+		return float64(v.AsInt)
+	}
+	return dp.GetAsDouble()
 }
 
 func serializeAttributes(name string, attrs ...[]*v1.KeyValue) string {
@@ -0,0 +1,301 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// Transports of syslog messages
+const (
+	TransportSyslogUDP = "udp/syslog"
+	TransportSyslogTCP = "tcp/syslog"
+)
+
+// syslogSeverities are the syslog severities, by their number
+var syslogSeverities = []struct {
+	text   string
+	number logs.SeverityNumber
+}{
+	{"emerg", logs.SeverityNumber_SEVERITY_NUMBER_FATAL4},
+	{"alert", logs.SeverityNumber_SEVERITY_NUMBER_FATAL},
+	{"crit", logs.SeverityNumber_SEVERITY_NUMBER_ERROR3},
+	{"err", logs.SeverityNumber_SEVERITY_NUMBER_ERROR},
+	{"warning", logs.SeverityNumber_SEVERITY_NUMBER_WARN},
+	{"notice", logs.SeverityNumber_SEVERITY_NUMBER_INFO2},
+	{"info", logs.SeverityNumber_SEVERITY_NUMBER_INFO},
+	{"debug", logs.SeverityNumber_SEVERITY_NUMBER_DEBUG},
+}
+
+// syslogMessage is a parsed RFC 5424 or RFC 3164 message
+type syslogMessage struct {
+	hostname string
+	appName  string
+	log      *logs.LogRecord
+}
+
+// listenSyslogUDP receives a syslog message per datagram on pc until ctx is done
+func listenSyslogUDP(ctx context.Context, pc net.PacketConn) {
+	go func() {
+		<-ctx.Done()
+		pc.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.ErrorContext(ctx, "syslog UDP receiver read error", "err", err)
+			}
+			return
+		}
+		r := &request{transport: TransportSyslogUDP, peer: Peer{Addr: hostOf(addr.String())}, start: time.Now(), bytes: n}
+		req := syslogRequest([]syslogMessage{parseSyslog(strings.TrimRight(string(buf[:n]), "\r\n\x00"))})
+		observe(r, req, receive(req))
+	}
+}
+
+// listenSyslogTCP accepts syslog connections on l until ctx is done
+func listenSyslogTCP(ctx context.Context, l net.Listener) {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.ErrorContext(ctx, "syslog TCP receiver accept error", "err", err)
+			}
+			return
+		}
+		go readSyslogConn(ctx, conn)
+	}
+}
+
+// readSyslogConn reads messages framed by octet counting, or by newlines, as in RFC 6587
+func readSyslogConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	br := bufio.NewReaderSize(conn, 64*1024)
+	var (
+		batch []syslogMessage
+		size  int
+	)
+	for {
+		msg, err := readSyslogFrame(br)
+		if len(msg) > 0 {
+			size += len(msg)
+			batch = append(batch, parseSyslog(msg))
+		}
+		// messages are received in batches of what arrived together
+		if len(batch) > 0 && (err != nil || br.Buffered() == 0) {
+			r := &request{transport: TransportSyslogTCP, peer: Peer{Addr: hostOf(conn.RemoteAddr().String())}, start: time.Now(), bytes: size}
+			req := syslogRequest(batch)
+			observe(r, req, receive(req))
+			batch, size = nil, 0
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				slog.WarnContext(ctx, "syslog TCP connection error", "peer", conn.RemoteAddr(), "err", err)
+			}
+			return
+		}
+	}
+}
+
+func readSyslogFrame(br *bufio.Reader) (string, error) {
+	b, err := br.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	if b[0] >= '1' && b[0] <= '9' {
+		n, err := br.ReadString(' ')
+		if err != nil {
+			return "", err
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(n, " "))
+		if err != nil || size > 1024*1024 {
+			return "", fmt.Errorf("invalid syslog frame length %q", n)
+		}
+		msg := make([]byte, size)
+		if _, err := io.ReadFull(br, msg); err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(msg), "\r\n"), nil
+	}
+	line, err := br.ReadString('\n')
+	if err != nil && line != "" && errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n\x00"), err
+}
+
+// syslogRequest groups messages by the host and app that sent them, which make up their resource
+func syslogRequest(msgs []syslogMessage) *collogs.ExportLogsServiceRequest {
+	req := &collogs.ExportLogsServiceRequest{}
+	byResource := map[[2]string]*logs.ScopeLogs{}
+	for _, m := range msgs {
+		key := [2]string{m.hostname, m.appName}
+		sl, ok := byResource[key]
+		if !ok {
+			res := &resource.Resource{}
+			if m.appName != "" {
+				res.Attributes = append(res.Attributes, stringAttr("service.name", m.appName))
+			}
+			if m.hostname != "" {
+				res.Attributes = append(res.Attributes, stringAttr("host.name", m.hostname))
+			}
+			sl = &logs.ScopeLogs{Scope: &v1.InstrumentationScope{Name: "otelui/syslog"}}
+			byResource[key] = sl
+			req.ResourceLogs = append(req.ResourceLogs, &logs.ResourceLogs{Resource: res, ScopeLogs: []*logs.ScopeLogs{sl}})
+		}
+		sl.LogRecords = append(sl.LogRecords, m.log)
+	}
+	return req
+}
+
+// parseSyslog parses an RFC 5424 or RFC 3164 message, anything it can't parse ends up in the body
+func parseSyslog(s string) syslogMessage {
+	now := uint64(time.Now().UnixNano())
+	m := syslogMessage{log: &logs.LogRecord{TimeUnixNano: now, ObservedTimeUnixNano: now}}
+	l := m.log
+
+	// <PRI>, the facility and severity
+	if rest, ok := strings.CutPrefix(s, "<"); ok {
+		if end := strings.IndexByte(rest, '>'); end > 0 && end <= 3 {
+			if pri, err := strconv.Atoi(rest[:end]); err == nil && pri <= 191 {
+				sev := syslogSeverities[pri%8]
+				l.SeverityText, l.SeverityNumber = sev.text, sev.number
+				l.Attributes = append(l.Attributes, &v1.KeyValue{Key: "syslog.facility", Value: &v1.AnyValue{Value: &v1.AnyValue_IntValue{IntValue: int64(pri / 8)}}})
+				s = rest[end+1:]
+			}
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(s, "1 "); ok {
+		parseSyslog5424(&m, rest)
+	} else {
+		parseSyslog3164(&m, s)
+	}
+	return m
+}
+
+// parseSyslog5424 parses TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG, where - is nil
+func parseSyslog5424(m *syslogMessage, s string) {
+	l := m.log
+	fields := strings.SplitN(s, " ", 6)
+	if len(fields) < 6 {
+		l.Body = stringValueOf(s)
+		return
+	}
+	value := func(f string) string {
+		if f == "-" {
+			return ""
+		}
+		return f
+	}
+	if t, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		l.TimeUnixNano = uint64(t.UnixNano())
+	}
+	m.hostname, m.appName = value(fields[1]), value(fields[2])
+	if procID := value(fields[3]); procID != "" {
+		l.Attributes = append(l.Attributes, stringAttr("syslog.procid", procID))
+	}
+	if msgID := value(fields[4]); msgID != "" {
+		l.Attributes = append(l.Attributes, stringAttr("syslog.msgid", msgID))
+	}
+
+	rest := fields[5]
+	if strings.HasPrefix(rest, "-") {
+		rest = rest[1:]
+	} else {
+		var attrs []*v1.KeyValue
+		attrs, rest = parseStructuredData(rest)
+		l.Attributes = append(l.Attributes, attrs...)
+	}
+	rest = strings.TrimPrefix(rest, " ")
+	rest = strings.TrimPrefix(rest, "\ufeff") // BOM of UTF-8 messages
+	l.Body = stringValueOf(rest)
+}
+
+// parseStructuredData parses [id param="value" ...] elements into syslog.sd.<id>.<param> attributes
+func parseStructuredData(s string) ([]*v1.KeyValue, string) {
+	var attrs []*v1.KeyValue
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+		end := strings.IndexAny(s, " ]")
+		if end < 0 {
+			return attrs, s
+		}
+		id := s[:end]
+		s = s[end:]
+		for strings.HasPrefix(s, " ") {
+			s = s[1:]
+			eq := strings.Index(s, `="`)
+			if eq < 0 {
+				return attrs, s
+			}
+			name := s[:eq]
+			s = s[eq+2:]
+			var value strings.Builder
+			for len(s) > 0 && s[0] != '"' {
+				if s[0] == '\\' && len(s) > 1 {
+					s = s[1:]
+				}
+				value.WriteByte(s[0])
+				s = s[1:]
+			}
+			s = strings.TrimPrefix(s, `"`)
+			attrs = append(attrs, stringAttr("syslog.sd."+id+"."+name, value.String()))
+		}
+		s = strings.TrimPrefix(s, "]")
+	}
+	return attrs, s
+}
+
+// parseSyslog3164 parses Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG, which has no year or timezone
+func parseSyslog3164(m *syslogMessage, s string) {
+	l := m.log
+	if len(s) >= 16 {
+		if t, err := time.Parse(time.Stamp, s[:15]); err == nil {
+			now := time.Now()
+			t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+			// messages from the last days of December arriving in January
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			l.TimeUnixNano = uint64(t.UnixNano())
+			s = s[16:]
+			if host, rest, ok := strings.Cut(s, " "); ok {
+				m.hostname, s = host, rest
+			}
+		}
+	}
+
+	// TAG is alphanumeric, optionally followed by [PID], and ends with a colon
+	if tag, rest, ok := strings.Cut(s, ": "); ok && !strings.ContainsAny(tag, " \t") {
+		if i := strings.IndexByte(tag, '['); i > 0 && strings.HasSuffix(tag, "]") {
+			l.Attributes = append(l.Attributes, stringAttr("syslog.procid", tag[i+1:len(tag)-1]))
+			tag = tag[:i]
+		}
+		m.appName, s = tag, rest
+	}
+	l.Body = stringValueOf(s)
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestParseSyslog(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      string
+		hostname string
+		appName  string
+		severity string
+		time     time.Time // checked unless zero
+		attrs    map[string]string
+		body     string
+	}{
+		{
+			name:     "RFC 5424",
+			msg:      "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An application event",
+			hostname: "mymachine.example.com", appName: "evntslog", severity: "notice",
+			time:  time.Date(2003, 10, 11, 22, 14, 15, 3e6, time.UTC),
+			attrs: map[string]string{"syslog.facility": "20", "syslog.msgid": "ID47"},
+			body:  "An application event",
+		},
+		{
+			name: "RFC 5424 structured data",
+			msg: `<165>1 2003-10-11T22:14:15.003Z host app 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Application"]` +
+				`[examplePriority@32473 class="high \"quoted\" \]"] ` + "\ufeffAn application event",
+			hostname: "host", appName: "app", severity: "notice",
+			time: time.Date(2003, 10, 11, 22, 14, 15, 3e6, time.UTC),
+			attrs: map[string]string{
+				"syslog.facility":                         "20",
+				"syslog.procid":                           "1234",
+				"syslog.msgid":                            "ID47",
+				"syslog.sd.exampleSDID@32473.iut":         "3",
+				"syslog.sd.exampleSDID@32473.eventSource": "Application",
+				"syslog.sd.examplePriority@32473.class":   `high "quoted" ]`,
+			},
+			body: "An application event",
+		},
+		{
+			name:     "RFC 5424 structured data without message",
+			msg:      `<11>1 - - - - - [origin ip="192.0.2.1"]`,
+			severity: "err",
+			attrs:    map[string]string{"syslog.facility": "1", "syslog.sd.origin.ip": "192.0.2.1"},
+		},
+		{
+			name:     "RFC 5424 nil values",
+			msg:      "<14>1 - - - - - -",
+			severity: "info",
+			attrs:    map[string]string{"syslog.facility": "1"},
+		},
+		{
+			name:     "RFC 5424 missing fields",
+			msg:      "<14>1 2003-10-11T22:14:15Z host",
+			severity: "info",
+			attrs:    map[string]string{"syslog.facility": "1"},
+			body:     "2003-10-11T22:14:15Z host",
+		},
+		{
+			name:     "RFC 3164",
+			msg:      "<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8",
+			hostname: "mymachine", appName: "su", severity: "crit",
+			attrs: map[string]string{"syslog.facility": "4", "syslog.procid": "230"},
+			body:  "'su root' failed for lonvick on /dev/pts/8",
+		},
+		{
+			name:     "RFC 3164 without PID",
+			msg:      "<13>Feb  5 17:32:18 10.0.0.99 myapp: Use the BFG!",
+			hostname: "10.0.0.99", appName: "myapp", severity: "notice",
+			attrs: map[string]string{"syslog.facility": "1"},
+			body:  "Use the BFG!",
+		},
+		{
+			name:     "RFC 3164 without tag",
+			msg:      "<13>Feb  5 17:32:18 host just a message",
+			hostname: "host", severity: "notice",
+			attrs: map[string]string{"syslog.facility": "1"},
+			body:  "just a message",
+		},
+		{
+			name:    "no priority",
+			msg:     "kernel: out of memory",
+			appName: "kernel",
+			attrs:   map[string]string{},
+			body:    "out of memory",
+		},
+		{
+			name:  "invalid priority",
+			msg:   "<999>hello",
+			attrs: map[string]string{},
+			body:  "<999>hello",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := parseSyslog(tt.msg)
+			l := m.log
+			if m.hostname != tt.hostname || m.appName != tt.appName {
+				t.Errorf("hostname %q and app %q, want %q and %q", m.hostname, m.appName, tt.hostname, tt.appName)
+			}
+			if l.SeverityText != tt.severity {
+				t.Errorf("severity %q, want %q", l.SeverityText, tt.severity)
+			}
+			if body := l.Body.GetStringValue(); body != tt.body {
+				t.Errorf("body %q, want %q", body, tt.body)
+			}
+			if attrs := attrStrings(l.Attributes); !maps.Equal(attrs, tt.attrs) {
+				t.Errorf("attributes %v, want %v", attrs, tt.attrs)
+			}
+			if !tt.time.IsZero() && l.TimeUnixNano != uint64(tt.time.UnixNano()) {
+				t.Errorf("time %v, want %v", time.Unix(0, int64(l.TimeUnixNano)).UTC(), tt.time)
+			}
+		})
+	}
+}
+
+func TestParseSyslog3164Time(t *testing.T) {
+	m := parseSyslog("<13>Oct 11 22:14:15 host app: message")
+	got := time.Unix(0, int64(m.log.TimeUnixNano)).In(time.Local)
+	if got.Month() != time.October || got.Day() != 11 || got.Hour() != 22 || got.Minute() != 14 || got.Second() != 15 {
+		t.Errorf("time %v, want Oct 11 22:14:15 local time", got)
+	}
+	if year := time.Now().Year(); got.Year() != year && got.Year() != year-1 {
+		t.Errorf("year %d, want this year or the last", got.Year())
+	}
+}
@@ -0,0 +1,301 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// TransportTail is reading log lines from files or stdin
+const TransportTail = "file/tail"
+
+// tailPoll is how often a followed file is checked for new lines
+const tailPoll = 250 * time.Millisecond
+
+// tail reads the lines of a file, - for stdin, as logs. Files are followed like tail -F does,
+// and read again from the start if they are truncated or replaced.
+func tail(ctx context.Context, path string) {
+	name := filepath.Base(path)
+	attrs := []*v1.KeyValue{stringAttr("log.file.path", path), stringAttr("log.file.name", name)}
+	if path == "-" {
+		name = "stdin"
+		attrs = nil
+	}
+	res := &resource.Resource{Attributes: append([]*v1.KeyValue{stringAttr("service.name", name)}, attrs...)}
+	scope := &v1.InstrumentationScope{Name: "otelui/tail"}
+	r := &request{transport: TransportTail, peer: Peer{Addr: name}}
+
+	if path == "-" {
+		if err := readLines(ctx, os.Stdin, r, res, scope); err != nil && !errors.Is(err, io.EOF) {
+			slog.ErrorContext(ctx, "failed to read stdin", "err", err)
+		}
+		return
+	}
+
+	var (
+		f    *os.File
+		info os.FileInfo
+	)
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+	for {
+		if f == nil {
+			var err error
+			if f, err = os.Open(path); err == nil {
+				info, _ = f.Stat()
+			} else if !errors.Is(err, os.ErrNotExist) {
+				slog.ErrorContext(ctx, "failed to tail", "path", path, "err", err)
+				return
+			}
+		}
+		if f != nil {
+			if err := readLines(ctx, f, r, res, scope); err != nil && !errors.Is(err, io.EOF) {
+				slog.ErrorContext(ctx, "failed to tail", "path", path, "err", err)
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tailPoll):
+		}
+
+		if f == nil {
+			continue
+		}
+		// start over if the file was rotated or truncated
+		pos, _ := f.Seek(0, io.SeekCurrent)
+		if cur, err := os.Stat(path); err != nil || !os.SameFile(info, cur) || cur.Size() < pos {
+			f.Close()
+			f = nil
+		}
+	}
+}
+
+// readLines receives the complete lines read from r as logs, a batch per read, until EOF.
+// Lines longer than 64KB are split.
+func readLines(ctx context.Context, rd io.Reader, r *request, res *resource.Resource, scope *v1.InstrumentationScope) error {
+	br := bufio.NewReaderSize(rd, 64*1024)
+	var (
+		batch []*logs.LogRecord
+		size  int
+	)
+	for {
+		line, err := br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			err = nil
+		} else if err != nil && len(line) > 0 {
+			// an incomplete last line is only read once it is complete
+			if s, ok := rd.(io.Seeker); ok {
+				if _, err := s.Seek(-int64(len(line)), io.SeekCurrent); err == nil {
+					line = nil
+				}
+			}
+		}
+		if len(line) > 0 {
+			size += len(line)
+			if l := parseLine(strings.TrimRight(string(line), "\r\n")); l != nil {
+				batch = append(batch, l)
+			}
+		}
+		if len(batch) > 0 && (err != nil || br.Buffered() == 0 || len(batch) >= 1000) {
+			r.start, r.bytes = time.Now(), size
+			req := &collogs.ExportLogsServiceRequest{ResourceLogs: []*logs.ResourceLogs{{
+				Resource:  res,
+				ScopeLogs: []*logs.ScopeLogs{{Scope: scope, LogRecords: batch}},
+			}}}
+			observe(r, req, receive(req))
+			batch, size = nil, 0
+		}
+		if err != nil || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// parseLine turns a line into a log, parsing JSON lines like structured loggers write them
+// and guessing the severity and timestamp of plain text ones. Blank lines are skipped.
+func parseLine(line string) *logs.LogRecord {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	now := uint64(time.Now().UnixNano())
+	// without a timestamp logs would be sorted before all others, so they get the observed one
+	l := &logs.LogRecord{TimeUnixNano: now, ObservedTimeUnixNano: now}
+
+	var fields map[string]any
+	if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &fields) == nil {
+		l.Body = stringValueOf(line)
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := fields[k]
+			s, isString := v.(string)
+			switch strings.ToLower(k) {
+			case "msg", "message", "body":
+				l.Body = jsonToAny(v)
+				continue
+			case "level", "severity", "lvl", "log.level", "severity_text", "severitytext":
+				if isString {
+					l.SeverityText = s
+					l.SeverityNumber = parseSeverity(s)
+					continue
+				}
+			case "time", "timestamp", "ts", "@timestamp":
+				if ts, ok := parseTimestamp(v); ok {
+					l.TimeUnixNano = ts
+					continue
+				}
+			case "trace_id", "traceid", "trace.id":
+				// other IDs are kept as attributes, as the log would be rejected for them
+				if id, err := hex.DecodeString(s); isString && len(id) == 16 && err == nil {
+					l.TraceId = id
+					continue
+				}
+			case "span_id", "spanid", "span.id":
+				if id, err := hex.DecodeString(s); isString && len(id) == 8 && err == nil {
+					l.SpanId = id
+					continue
+				}
+			}
+			l.Attributes = append(l.Attributes, &v1.KeyValue{Key: k, Value: jsonToAny(v)})
+		}
+		return l
+	}
+
+	l.Body = stringValueOf(line)
+	// the level is usually among the first words, in capitals or as level=info
+	words := strings.Fields(line)
+	for _, word := range words[:min(len(words), 8)] {
+		k, v, ok := strings.Cut(word, "=")
+		if ok && (k == "level" || k == "lvl" || k == "severity") {
+			word = strings.Trim(v, `"`)
+		} else if word = strings.Trim(word, "[]():|"); strings.ToUpper(word) != word {
+			continue
+		}
+		if n := parseSeverity(word); n != 0 {
+			l.SeverityText, l.SeverityNumber = word, n
+			break
+		}
+	}
+	// a leading date, eg. 2025-01-02T15:04:05Z or 2025-01-02 15:04:05.123
+	if len(words) > 0 && strings.Count(words[0], "-") == 2 {
+		candidates := []string{words[0]}
+		if len(words) > 1 {
+			candidates = append(candidates, words[0]+" "+words[1])
+		}
+		for _, c := range candidates {
+			if ts, ok := parseTimestamp(c); ok {
+				l.TimeUnixNano = ts
+			}
+		}
+	}
+	return l
+}
+
+// parseSeverity maps common level names, in any case, to a severity number, 0 if unknown
+func parseSeverity(s string) logs.SeverityNumber {
+	switch strings.ToLower(s) {
+	case "trace":
+		return logs.SeverityNumber_SEVERITY_NUMBER_TRACE
+	case "debug", "dbg":
+		return logs.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case "info", "inf", "information", "informational":
+		return logs.SeverityNumber_SEVERITY_NUMBER_INFO
+	case "notice":
+		return logs.SeverityNumber_SEVERITY_NUMBER_INFO2
+	case "warn", "wrn", "warning":
+		return logs.SeverityNumber_SEVERITY_NUMBER_WARN
+	case "error", "err", "eror":
+		return logs.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case "crit", "critical":
+		return logs.SeverityNumber_SEVERITY_NUMBER_ERROR3
+	case "fatal", "panic", "alert":
+		return logs.SeverityNumber_SEVERITY_NUMBER_FATAL
+	case "emerg", "emergency":
+		return logs.SeverityNumber_SEVERITY_NUMBER_FATAL4
+	}
+	return 0
+}
+
+// parseTimestamp parses RFC 3339 strings, and Unix times in seconds, milliseconds or nanoseconds
+func parseTimestamp(v any) (uint64, bool) {
+	switch v := v.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999"} {
+			if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+				return uint64(t.UnixNano()), true
+			}
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return parseTimestamp(f)
+		}
+	case float64:
+		switch {
+		case v <= 0:
+		case v < 1e11:
+			return uint64(v * 1e9), true
+		case v < 1e14:
+			return uint64(v * 1e6), true
+		case v < 1e17:
+			return uint64(v * 1e3), true
+		default:
+			return uint64(v), true
+		}
+	}
+	return 0, false
+}
+
+// jsonToAny converts a value decoded from JSON to an attribute value
+func jsonToAny(v any) *v1.AnyValue {
+	switch v := v.(type) {
+	case string:
+		return stringValueOf(v)
+	case bool:
+		return &v1.AnyValue{Value: &v1.AnyValue_BoolValue{BoolValue: v}}
+	case float64:
+		if v == float64(int64(v)) {
+			return &v1.AnyValue{Value: &v1.AnyValue_IntValue{IntValue: int64(v)}}
+		}
+		return &v1.AnyValue{Value: &v1.AnyValue_DoubleValue{DoubleValue: v}}
+	case []any:
+		arr := &v1.ArrayValue{}
+		for _, e := range v {
+			arr.Values = append(arr.Values, jsonToAny(e))
+		}
+		return &v1.AnyValue{Value: &v1.AnyValue_ArrayValue{ArrayValue: arr}}
+	case map[string]any:
+		kvs := &v1.KeyValueList{}
+		for k, e := range v {
+			kvs.Values = append(kvs.Values, &v1.KeyValue{Key: k, Value: jsonToAny(e)})
+		}
+		return &v1.AnyValue{Value: &v1.AnyValue_KvlistValue{KvlistValue: kvs}}
+	}
+	return &v1.AnyValue{}
+}
+
+func stringValueOf(s string) *v1.AnyValue {
+	return &v1.AnyValue{Value: &v1.AnyValue_StringValue{StringValue: s}}
+}
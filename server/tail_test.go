@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/hex"
+	"maps"
+	"testing"
+	"time"
+
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		severity logs.SeverityNumber
+		time     time.Time // checked unless zero
+		body     string
+		traceID  string
+		spanID   string
+		attrs    map[string]string
+	}{
+		{
+			name:     "JSON",
+			line:     `{"level":"warn","msg":"slow query","ts":"2025-01-02T15:04:05Z","trace_id":"5b8efff798038103d269b633813fc60c","span_id":"eee19b7ec3c1b174","db":"users"}`,
+			severity: logs.SeverityNumber_SEVERITY_NUMBER_WARN,
+			time:     time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC),
+			body:     "slow query",
+			traceID:  "5b8efff798038103d269b633813fc60c",
+			spanID:   "eee19b7ec3c1b174",
+			attrs:    map[string]string{"db": "users"},
+		},
+		{
+			name:  "IDs that aren't hex",
+			line:  `{"msg":"hi","trace_id":"request-0000000000000000000000a","span_id":"worker-000000001"}`,
+			body:  "hi",
+			attrs: map[string]string{"trace_id": "request-0000000000000000000000a", "span_id": "worker-000000001"},
+		},
+		{
+			name:  "IDs of the wrong length",
+			line:  `{"msg":"hi","traceId":"abcd","spanId":"5b8efff798038103d269b633813fc60c"}`,
+			body:  "hi",
+			attrs: map[string]string{"traceId": "abcd", "spanId": "5b8efff798038103d269b633813fc60c"},
+		},
+		{
+			name:     "plain text",
+			line:     "2025-01-02 15:04:05.5Z ERROR disk full",
+			severity: logs.SeverityNumber_SEVERITY_NUMBER_ERROR,
+			time:     time.Date(2025, 1, 2, 15, 4, 5, 5e8, time.UTC),
+			body:     "2025-01-02 15:04:05.5Z ERROR disk full",
+			attrs:    map[string]string{},
+		},
+		{
+			name:     "logfmt level",
+			line:     `time=now level="info" msg=started`,
+			severity: logs.SeverityNumber_SEVERITY_NUMBER_INFO,
+			body:     `time=now level="info" msg=started`,
+			attrs:    map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := parseLine(tt.line)
+			if err := validateLog(l); err != nil {
+				t.Fatalf("invalid log: %v", err)
+			}
+			if l.SeverityNumber != tt.severity || l.Body.GetStringValue() != tt.body {
+				t.Errorf("severity %v and body %q, want %v and %q", l.SeverityNumber, l.Body.GetStringValue(), tt.severity, tt.body)
+			}
+			if !tt.time.IsZero() && l.TimeUnixNano != uint64(tt.time.UnixNano()) {
+				t.Errorf("time %v, want %v", time.Unix(0, int64(l.TimeUnixNano)).UTC(), tt.time)
+			}
+			if hex.EncodeToString(l.TraceId) != tt.traceID || hex.EncodeToString(l.SpanId) != tt.spanID {
+				t.Errorf("trace ID %x and span ID %x, want %s and %s", l.TraceId, l.SpanId, tt.traceID, tt.spanID)
+			}
+			if attrs := attrStrings(l.Attributes); !maps.Equal(attrs, tt.attrs) {
+				t.Errorf("attributes %v, want %v", attrs, tt.attrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Thrift types of the binary protocol
+const (
+	thriftStop   byte = 0
+	thriftBool   byte = 2
+	thriftByte   byte = 3
+	thriftDouble byte = 4
+	thriftI16    byte = 6
+	thriftI32    byte = 8
+	thriftI64    byte = 10
+	thriftString byte = 11
+	thriftStruct byte = 12
+	thriftMap    byte = 13
+	thriftSet    byte = 14
+	thriftList   byte = 15
+)
+
+var errThriftShort = errors.New("thrift: unexpected end of data")
+
+// thriftReader reads values encoded with the Thrift binary protocol, the first error sticks
+type thriftReader struct {
+	b     []byte
+	err   error
+	depth int
+}
+
+func (r *thriftReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.b) {
+		r.err = errThriftShort
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *thriftReader) byte() byte {
+	if v := r.next(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (r *thriftReader) bool() bool { return r.byte() != 0 }
+
+func (r *thriftReader) i16() int16 {
+	if v := r.next(2); v != nil {
+		return int16(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (r *thriftReader) i32() int32 {
+	if v := r.next(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (r *thriftReader) i64() int64 {
+	if v := r.next(8); v != nil {
+		return int64(binary.BigEndian.Uint64(v))
+	}
+	return 0
+}
+
+func (r *thriftReader) double() float64 { return math.Float64frombits(uint64(r.i64())) }
+
+func (r *thriftReader) binary() []byte { return r.next(int(r.i32())) }
+
+func (r *thriftReader) string() string { return string(r.binary()) }
+
+// structFields calls fn for every field of a struct, fn must read or skip the value
+func (r *thriftReader) structFields(fn func(id int16, typ byte)) {
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > 64 {
+		r.err = errors.New("thrift: structs nested too deep")
+		return
+	}
+	for r.err == nil {
+		typ := r.byte()
+		if typ == thriftStop {
+			return
+		}
+		fn(r.i16(), typ)
+	}
+}
+
+// list calls fn for every element of a list or set, fn must read or skip the element
+func (r *thriftReader) list(fn func(typ byte)) {
+	typ := r.byte()
+	n := r.i32()
+	if n < 0 || int(n) > len(r.b) {
+		if r.err == nil {
+			r.err = fmt.Errorf("thrift: invalid list size %d", n)
+		}
+		return
+	}
+	for i := int32(0); i < n && r.err == nil; i++ {
+		fn(typ)
+	}
+}
+
+// skip reads a value of type typ without decoding it
+func (r *thriftReader) skip(typ byte) {
+	switch typ {
+	case thriftBool, thriftByte:
+		r.next(1)
+	case thriftI16:
+		r.next(2)
+	case thriftI32:
+		r.next(4)
+	case thriftDouble, thriftI64:
+		r.next(8)
+	case thriftString:
+		r.binary()
+	case thriftStruct:
+		r.structFields(func(_ int16, typ byte) { r.skip(typ) })
+	case thriftMap:
+		kt, vt, n := r.byte(), r.byte(), r.i32()
+		if n < 0 || int(n) > len(r.b) {
+			if r.err == nil {
+				r.err = fmt.Errorf("thrift: invalid map size %d", n)
+			}
+			return
+		}
+		for i := int32(0); i < n && r.err == nil; i++ {
+			r.skip(kt)
+			r.skip(vt)
+		}
+	case thriftSet, thriftList:
+		r.list(r.skip)
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("thrift: unknown type %d", typ)
+		}
+	}
+}
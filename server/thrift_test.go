@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// thriftWriter encodes values with the Thrift binary protocol
+type thriftWriter struct{ bytes.Buffer }
+
+func (w *thriftWriter) field(typ byte, id int16) *thriftWriter {
+	w.WriteByte(typ)
+	return w.i16(id)
+}
+
+func (w *thriftWriter) stop() *thriftWriter { w.WriteByte(thriftStop); return w }
+
+func (w *thriftWriter) i16(v int16) *thriftWriter {
+	w.Write(binary.BigEndian.AppendUint16(nil, uint16(v)))
+	return w
+}
+
+func (w *thriftWriter) i32(v int32) *thriftWriter {
+	w.Write(binary.BigEndian.AppendUint32(nil, uint32(v)))
+	return w
+}
+
+func (w *thriftWriter) i64(v int64) *thriftWriter {
+	w.Write(binary.BigEndian.AppendUint64(nil, uint64(v)))
+	return w
+}
+
+func (w *thriftWriter) string(s string) *thriftWriter {
+	w.i32(int32(len(s)))
+	w.WriteString(s)
+	return w
+}
+
+func (w *thriftWriter) list(typ byte, n int32) *thriftWriter {
+	w.WriteByte(typ)
+	return w.i32(n)
+}
+
+// tag writes a jaeger.thrift Tag of type vType, whose value field is written by value
+func (w *thriftWriter) tag(key string, vType int32, value func()) *thriftWriter {
+	w.field(thriftString, 1).string(key)
+	w.field(thriftI32, 2).i32(vType)
+	value()
+	return w.stop()
+}
+
+// testJaegerBatch is a Batch with a process and a span with a reference, tags of every type, a log and unknown fields
+func testJaegerBatch() []byte {
+	w := &thriftWriter{}
+	w.field(thriftStruct, 1) // process
+	w.field(thriftString, 1).string("checkout")
+	w.field(thriftList, 2).list(thriftStruct, 1)
+	w.tag("hostname", 0, func() { w.field(thriftString, 3).string("web-1") })
+	w.stop()
+
+	w.field(thriftList, 2).list(thriftStruct, 1) // spans
+	w.field(thriftI64, 1).i64(2)                 // trace ID low
+	w.field(thriftI64, 2).i64(1)                 // trace ID high
+	w.field(thriftI64, 3).i64(3)
+	w.field(thriftI64, 4).i64(4)
+	w.field(thriftString, 5).string("charge")
+	w.field(thriftList, 6).list(thriftStruct, 1)
+	w.field(thriftI32, 1).i32(1) // FOLLOWS_FROM
+	w.field(thriftI64, 2).i64(2)
+	w.field(thriftI64, 3).i64(1)
+	w.field(thriftI64, 4).i64(5)
+	w.stop()
+	w.field(thriftI32, 7).i32(0) // flags, unknown
+	w.field(thriftI64, 8).i64(1_000_000)
+	w.field(thriftI64, 9).i64(250)
+	w.field(thriftList, 10).list(thriftStruct, 5)
+	w.tag("s", 0, func() { w.field(thriftString, 3).string("v") })
+	w.tag("d", 1, func() { w.field(thriftDouble, 4).i64(int64(math.Float64bits(1.5))) })
+	w.tag("b", 2, func() { w.field(thriftBool, 5).WriteByte(1) })
+	w.tag("i", 3, func() { w.field(thriftI64, 6).i64(-7) })
+	w.tag("bin", 4, func() { w.field(thriftString, 7).string("\x01\x02") })
+	w.field(thriftList, 11).list(thriftStruct, 1)
+	w.field(thriftI64, 1).i64(1_000_100)
+	w.field(thriftList, 2).list(thriftStruct, 1)
+	w.tag("event", 0, func() { w.field(thriftString, 3).string("retry") })
+	w.stop()
+	w.field(thriftMap, 12).WriteByte(thriftString) // unknown map<string, i32>
+	w.WriteByte(thriftI32)
+	w.i32(1).string("k").i32(1)
+	w.field(thriftSet, 13).list(thriftI16, 2).i16(1).i16(2) // unknown set<i16>
+	w.stop()
+
+	w.field(thriftStruct, 3).field(thriftByte, 1) // unknown struct
+	w.WriteByte(9)
+	w.stop()
+	w.stop()
+	return w.Bytes()
+}
+
+func TestDecodeJaegerThrift(t *testing.T) {
+	spans, err := decodeJaegerThrift(testJaegerBatch())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 1 {
+		t.Fatalf("%d spans, want 1", len(spans))
+	}
+	s := spans[0]
+
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"service", s.process.service, "checkout"},
+		{"process tag", s.process.tags[0].Value.GetStringValue(), "web-1"},
+		{"trace ID", string(s.traceID), "\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x02"},
+		{"span ID", string(s.spanID), "\x00\x00\x00\x00\x00\x00\x00\x03"},
+		{"parent ID", string(s.parentID), "\x00\x00\x00\x00\x00\x00\x00\x04"},
+		{"name", s.name, "charge"},
+		{"reference", s.refs[0].followsFrom, true},
+		{"reference trace ID", string(s.refs[0].traceID), string(s.traceID)},
+		{"reference span ID", string(s.refs[0].spanID), "\x00\x00\x00\x00\x00\x00\x00\x05"},
+		{"start", s.start, uint64(1_000_000)},
+		{"duration", s.duration, uint64(250)},
+		{"string tag", s.tags[0].Value.GetStringValue(), "v"},
+		{"double tag", s.tags[1].Value.GetDoubleValue(), 1.5},
+		{"bool tag", s.tags[2].Value.GetBoolValue(), true},
+		{"long tag", s.tags[3].Value.GetIntValue(), int64(-7)},
+		{"binary tag", string(s.tags[4].Value.GetBytesValue()), "\x01\x02"},
+		{"log timestamp", s.logs[0].timestamp, uint64(1_000_100)},
+		{"log field", s.logs[0].fields[0].Key + "=" + s.logs[0].fields[0].Value.GetStringValue(), "event=retry"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s %#v, want %#v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestDecodeJaegerThriftErrors(t *testing.T) {
+	nested := &thriftWriter{}
+	for range 70 {
+		nested.field(thriftStruct, 3)
+	}
+	hugeMap := &thriftWriter{}
+	hugeMap.field(thriftMap, 9).WriteByte(thriftString)
+	hugeMap.list(thriftI32, math.MaxInt32)
+
+	tests := []struct {
+		name string
+		b    []byte
+		want string
+	}{
+		{"empty", nil, errThriftShort.Error()},
+		{"truncated", testJaegerBatch()[:100], errThriftShort.Error()},
+		{"truncated string", new(thriftWriter).field(thriftStruct, 1).field(thriftString, 1).i32(10).Bytes(), errThriftShort.Error()},
+		{"negative list size", new(thriftWriter).field(thriftList, 2).list(thriftStruct, -1).Bytes(), "thrift: invalid list size -1"},
+		{"huge list size", new(thriftWriter).field(thriftList, 2).list(thriftI64, math.MaxInt32).Bytes(), "thrift: invalid list size 2147483647"},
+		{"huge map size", hugeMap.Bytes(), "thrift: invalid map size"},
+		{"unknown type", new(thriftWriter).field(99, 9).Bytes(), "thrift: unknown type 99"},
+		{"nested too deep", nested.Bytes(), "thrift: structs nested too deep"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeJaegerThrift(tt.b)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("decodeJaegerThrift() error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleJaeger(t *testing.T) {
+	setupStorage(Limits{})
+	tests := []struct {
+		contentType string
+		body        []byte
+		status      int
+	}{
+		{"application/x-thrift", testJaegerBatch(), http.StatusAccepted},
+		{"application/vnd.apache.thrift.binary; charset=binary", testJaegerBatch(), http.StatusAccepted},
+		{"application/json", []byte("{}"), http.StatusUnsupportedMediaType},
+		{"application/x-thrift", []byte{1, 2}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/traces", bytes.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		handleJaeger(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.contentType, rec.Code, tt.status)
+		}
+	}
+	if n := len(Storage.traces); n != 1 {
+		t.Errorf("%d traces received, want 1", n)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// TLS configures TLS for both receivers
+type TLS struct {
+	// CertFile and KeyFile are the PEM encoded certificate and key of the receivers
+	CertFile, KeyFile string
+	// ClientCAFile is a PEM bundle to verify client certificates against, which are then required
+	ClientCAFile string
+	// SelfSigned generates a certificate in memory instead of loading one from files
+	SelfSigned bool
+}
+
+// tlsConfig loads the TLS configuration of the receivers, it is nil when TLS is not enabled
+func tlsConfig(t TLS) (*tls.Config, error) {
+	if t.CertFile == "" && t.KeyFile == "" && !t.SelfSigned {
+		if t.ClientCAFile != "" {
+			return nil, errors.New("a client CA requires a certificate or a self-signed one")
+		}
+		return nil, nil
+	}
+	if t.SelfSigned && (t.CertFile != "" || t.KeyFile != "") {
+		return nil, errors.New("a self-signed certificate can't be combined with a certificate file")
+	}
+	if !t.SelfSigned && (t.CertFile == "" || t.KeyFile == "") {
+		return nil, errors.New("both a certificate and a key file are required")
+	}
+
+	var (
+		cert tls.Certificate
+		err  error
+	)
+	if t.SelfSigned {
+		cert, err = selfSignedCert()
+	} else {
+		cert, err = tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if t.ClientCAFile != "" {
+		pem, err := os.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", t.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// selfSignedCert generates a certificate for localhost and the host name, valid for a year
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"otelui"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if host, err := os.Hostname(); err == nil && host != "localhost" {
+		tmpl.DNSNames = append(tmpl.DNSNames, host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
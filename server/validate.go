@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	traces "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// Rejected counts records that were dropped for being invalid
+type Rejected struct {
+	Logs       int
+	Spans      int
+	Datapoints int
+}
+
+// rejection counts the records dropped from a single request, and why the first one was
+type rejection struct {
+	count int
+	err   error
+}
+
+func (r *rejection) add(err error) {
+	r.count++
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// message is the error message of a partial success response
+func (r rejection) message() string {
+	if r.count == 0 {
+		return ""
+	}
+	if r.count == 1 {
+		return r.err.Error()
+	}
+	return fmt.Sprintf("%s (and %d more)", r.err, r.count-1)
+}
+
+func validateLog(l *logs.LogRecord) error {
+	if err := validateID("trace ID", l.TraceId, 16, true); err != nil {
+		return err
+	}
+	return validateID("span ID", l.SpanId, 8, true)
+}
+
+func validateSpan(s *traces.Span) error {
+	if err := validateID("trace ID", s.TraceId, 16, false); err != nil {
+		return err
+	}
+	if err := validateID("span ID", s.SpanId, 8, false); err != nil {
+		return err
+	}
+	return validateID("parent span ID", s.ParentSpanId, 8, true)
+}
+
+// validateID checks the length of an ID, a required one must also not be all zeroes
+func validateID(name string, id []byte, size int, optional bool) error {
+	if len(id) == 0 && optional {
+		return nil
+	}
+	if len(id) != size {
+		return fmt.Errorf("%s must be %d bytes, got %d", name, size, len(id))
+	}
+	if optional {
+		return nil
+	}
+	for _, b := range id {
+		if b != 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must not be all zeroes", name)
+}
+
+func validateMetric(m *metrics.Metric) error {
+	if m.Name == "" {
+		return errors.New("metric name must not be empty")
+	}
+	return nil
+}
+
+func validateNumber(dp *metrics.NumberDataPoint) error {
+	if dp.Value == nil {
+		return errors.New("number datapoint has no value")
+	}
+	return nil
+}
+
+func validateHistogram(dp *metrics.HistogramDataPoint) error {
+	if len(dp.BucketCounts) == 0 {
+		return nil
+	}
+	if len(dp.BucketCounts) != len(dp.ExplicitBounds)+1 {
+		return fmt.Errorf("histogram has %d bucket counts for %d bounds, expected %d", len(dp.BucketCounts), len(dp.ExplicitBounds), len(dp.ExplicitBounds)+1)
+	}
+	for i := 1; i < len(dp.ExplicitBounds); i++ {
+		if dp.ExplicitBounds[i] <= dp.ExplicitBounds[i-1] {
+			return errors.New("histogram bounds must be increasing")
+		}
+	}
+	var total uint64
+	for _, c := range dp.BucketCounts {
+		total += c
+	}
+	if total != dp.Count {
+		return fmt.Errorf("histogram count is %d but its buckets add up to %d", dp.Count, total)
+	}
+	return nil
+}
+
+func validateExponentialHistogram(dp *metrics.ExponentialHistogramDataPoint) error {
+	if len(dp.Positive.GetBucketCounts())+len(dp.Negative.GetBucketCounts()) == 0 && dp.ZeroCount == 0 {
+		return nil
+	}
+	total := dp.ZeroCount
+	for _, b := range []*metrics.ExponentialHistogramDataPoint_Buckets{dp.Positive, dp.Negative} {
+		for _, c := range b.GetBucketCounts() {
+			total += c
+		}
+	}
+	if total != dp.Count {
+		return fmt.Errorf("exponential histogram count is %d but its buckets add up to %d", dp.Count, total)
+	}
+	return nil
+}
+
+// datapointCount is the number of datapoints of a metric of any type
+func datapointCount(m *metrics.Metric) int {
+	switch d := m.Data.(type) {
+	case *metrics.Metric_Gauge:
+		return len(d.Gauge.DataPoints)
+	case *metrics.Metric_Sum:
+		return len(d.Sum.DataPoints)
+	case *metrics.Metric_Summary:
+		return len(d.Summary.DataPoints)
+	case *metrics.Metric_Histogram:
+		return len(d.Histogram.DataPoints)
+	case *metrics.Metric_ExponentialHistogram:
+		return len(d.ExponentialHistogram.DataPoints)
+	}
+	return 0
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	logs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	metrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	traces "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Records are framed as a little endian uint32 length and CRC-32 of the body,
+// followed by the body: a kind byte, the receive time as int64 unix nanoseconds
+// and the export request encoded as protobuf.
+const (
+	recordHeaderSize = 8
+	recordMaxSize    = 64 * 1024 * 1024
+
+	recordLogs    byte = 1
+	recordTraces  byte = 2
+	recordMetrics byte = 3
+
+	walFile = "otelui.wal"
+	// walPrevFile is the previous segment of the write-ahead file, replaced when walFile is rotated
+	walPrevFile = walFile + ".1"
+)
+
+var errCorruptRecord = errors.New("corrupt record")
+
+// files are where received requests are persisted
+var files struct {
+	sync.Mutex
+	wal    *os.File
+	record *os.File
+
+	// the write-ahead file is rotated once it is larger than half of walMaxSize, or older than walMaxAge,
+	// so that at most two segments are kept, see rotateWAL
+	walDir     string
+	walSize    int64
+	walStart   time.Time // when the first request of the current segment was received
+	walMaxSize int64
+	walMaxAge  time.Duration
+}
+
+// openWAL replays the write-ahead file in dir, and keeps it open for appending.
+// It is rotated to keep it under maxSize bytes, and requests received within maxAge, 0 for unlimited.
+func openWAL(dir string, maxSize int64, maxAge time.Duration) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+	replay := func(received time.Time, req proto.Message) error {
+		ingest(received, req)
+		return nil
+	}
+
+	if prev, err := os.Open(filepath.Join(dir, walPrevFile)); err == nil {
+		if _, err := readRecords(prev, replay); err != nil {
+			slog.Warn("ignoring corrupt tail of previous write-ahead file", "path", prev.Name(), "err", err)
+		}
+		prev.Close()
+	}
+
+	path := filepath.Join(dir, walFile)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	var start time.Time
+	good, err := readRecords(f, func(received time.Time, req proto.Message) error {
+		if start.IsZero() {
+			start = received
+		}
+		return replay(received, req)
+	})
+	if err != nil {
+		// a crash can leave a partially written record, everything before it is kept
+		slog.Warn("truncating corrupt tail of write-ahead file", "path", path, "offset", good, "err", err)
+		if err := f.Truncate(good); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to truncate %s: %w", path, err)
+		}
+	}
+	if _, err := f.Seek(good, io.SeekStart); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to seek %s: %w", path, err)
+	}
+
+	files.Lock()
+	files.wal, files.walDir, files.walSize, files.walStart = f, dir, good, start
+	files.walMaxSize, files.walMaxAge = maxSize, maxAge
+	files.Unlock()
+	return nil
+}
+
+// openRecording creates a recording at path, replacing any existing file
+func openRecording(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create recording: %w", err)
+	}
+	files.Lock()
+	files.record = f
+	files.Unlock()
+	return nil
+}
+
+// persist appends a request to the write-ahead file and recording, if there are any
+func persist(received time.Time, req proto.Message) {
+	files.Lock()
+	defer files.Unlock()
+	if files.wal != nil && ((files.walMaxSize > 0 && files.walSize > files.walMaxSize/2) ||
+		(files.walMaxAge > 0 && !files.walStart.IsZero() && received.Sub(files.walStart) > files.walMaxAge)) {
+		rotateWAL()
+	}
+	if files.wal != nil {
+		if files.walStart.IsZero() {
+			files.walStart = received
+		}
+		n, err := writeRecord(files.wal, received, req)
+		files.walSize += int64(n)
+		if err != nil {
+			slog.Error("failed to write to write-ahead file", "err", err)
+		}
+	}
+	if files.record != nil {
+		if _, err := writeRecord(files.record, received, req); err != nil {
+			slog.Error("failed to write to recording", "err", err)
+		}
+	}
+}
+
+// rotateWAL replaces the previous segment of the write-ahead file with the current one, and starts a new one.
+// Requests in the dropped segment are older than anything retained within the limits, or soon will be.
+// It must be called with files locked.
+func rotateWAL() {
+	path, prev := filepath.Join(files.walDir, walFile), filepath.Join(files.walDir, walPrevFile)
+	files.wal.Close()
+	if err := os.Rename(path, prev); err != nil {
+		slog.Error("failed to rotate write-ahead file", "err", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		slog.Error("failed to create write-ahead file, received telemetry won't be kept", "path", path, "err", err)
+	}
+	files.wal, files.walSize, files.walStart = f, 0, time.Time{}
+}
+
+// truncateWAL drops everything persisted so far, recordings are kept intact
+func truncateWAL() {
+	files.Lock()
+	defer files.Unlock()
+	if files.wal == nil {
+		return
+	}
+	if err := files.wal.Truncate(0); err != nil {
+		slog.Error("failed to truncate write-ahead file", "err", err)
+	}
+	files.wal.Seek(0, io.SeekStart)
+	files.walSize, files.walStart = 0, time.Time{}
+	if err := os.Remove(filepath.Join(files.walDir, walPrevFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("failed to remove previous write-ahead file", "err", err)
+	}
+}
+
+// closeFiles flushes and closes the write-ahead file and recording
+func closeFiles() {
+	files.Lock()
+	defer files.Unlock()
+	for _, f := range []*os.File{files.wal, files.record} {
+		if f != nil {
+			f.Sync()
+			f.Close()
+		}
+	}
+	files.wal, files.record = nil, nil
+}
+
+// writeRecord writes req as a single record, so a failed write leaves at most one corrupt record.
+// It returns the number of bytes written.
+func writeRecord(w io.Writer, received time.Time, req proto.Message) (int, error) {
+	var kind byte
+	switch req.(type) {
+	case *logs.ExportLogsServiceRequest:
+		kind = recordLogs
+	case *traces.ExportTraceServiceRequest:
+		kind = recordTraces
+	case *metrics.ExportMetricsServiceRequest:
+		kind = recordMetrics
+	default:
+		return 0, fmt.Errorf("unsupported request %T", req)
+	}
+
+	buf := make([]byte, recordHeaderSize+9, recordHeaderSize+9+proto.Size(req))
+	buf[recordHeaderSize] = kind
+	binary.LittleEndian.PutUint64(buf[recordHeaderSize+1:], uint64(received.UnixNano()))
+	buf, err := proto.MarshalOptions{}.MarshalAppend(buf, req)
+	if err != nil {
+		return 0, err
+	}
+	body := buf[recordHeaderSize:]
+	binary.LittleEndian.PutUint32(buf[0:], uint32(len(body)))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(body))
+
+	return w.Write(buf)
+}
+
+// readRecords calls fn for every record in r. It returns the offset after the last
+// valid record, and an error if reading stopped before the end of r or fn failed.
+func readRecords(r io.Reader, fn func(received time.Time, req proto.Message) error) (int64, error) {
+	br := bufio.NewReader(r)
+	var good int64
+	header := make([]byte, recordHeaderSize)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			if err == io.EOF {
+				return good, nil
+			}
+			return good, err
+		}
+		size := binary.LittleEndian.Uint32(header[0:])
+		if size < 9 || size > recordMaxSize {
+			return good, errCorruptRecord
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(br, body); err != nil {
+			return good, err
+		}
+		if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(header[4:]) {
+			return good, errCorruptRecord
+		}
+
+		var req proto.Message
+		switch body[0] {
+		case recordLogs:
+			req = &logs.ExportLogsServiceRequest{}
+		case recordTraces:
+			req = &traces.ExportTraceServiceRequest{}
+		case recordMetrics:
+			req = &metrics.ExportMetricsServiceRequest{}
+		default:
+			return good, errCorruptRecord
+		}
+		if err := proto.Unmarshal(body[9:], req); err != nil {
+			return good, errCorruptRecord
+		}
+
+		if err := fn(time.Unix(0, int64(binary.LittleEndian.Uint64(body[1:]))).UTC(), req); err != nil {
+			return good, err
+		}
+		good += int64(recordHeaderSize + len(body))
+	}
+}
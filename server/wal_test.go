@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltraces "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func testLogsRequest(body string) *collogs.ExportLogsServiceRequest {
+	return &collogs.ExportLogsServiceRequest{ResourceLogs: []*logs.ResourceLogs{{
+		ScopeLogs: []*logs.ScopeLogs{{LogRecords: []*logs.LogRecord{{TimeUnixNano: 1, Body: &v1.AnyValue{Value: &v1.AnyValue_StringValue{StringValue: body}}}}}},
+	}}}
+}
+
+// testRecords encodes a logs and a traces request, returning them and the offset after the first one
+func testRecords(t *testing.T) ([]byte, int) {
+	t.Helper()
+	var buf bytes.Buffer
+	received := time.Unix(1700000000, 5).UTC()
+	if _, err := writeRecord(&buf, received, testLogsRequest("first")); err != nil {
+		t.Fatal(err)
+	}
+	first := buf.Len()
+	if _, err := writeRecord(&buf, received, &coltraces.ExportTraceServiceRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), first
+}
+
+func TestReadRecords(t *testing.T) {
+	valid, first := testRecords(t)
+	corrupt := func(f func(b []byte) []byte) []byte { return f(bytes.Clone(valid)) }
+
+	tests := []struct {
+		name    string
+		b       []byte
+		records int
+		good    int
+		err     bool
+	}{
+		{"empty", nil, 0, 0, false},
+		{"valid", valid, 2, len(valid), false},
+		{"partial header", valid[:first+3], 1, first, true},
+		{"partial body", valid[:len(valid)-1], 1, first, true},
+		{"bad checksum", corrupt(func(b []byte) []byte { b[first+4]++; return b }), 1, first, true},
+		{"bad kind", corrupt(func(b []byte) []byte {
+			b[first+recordHeaderSize] = 9
+			binary.LittleEndian.PutUint32(b[first+4:], crc32.ChecksumIEEE(b[first+recordHeaderSize:]))
+			return b
+		}), 1, first, true},
+		{"too small", corrupt(func(b []byte) []byte { binary.LittleEndian.PutUint32(b[first:], 8); return b }), 1, first, true},
+		{"too large", corrupt(func(b []byte) []byte { binary.LittleEndian.PutUint32(b[first:], recordMaxSize+1); return b }), 1, first, true},
+		{"invalid protobuf", corrupt(func(b []byte) []byte {
+			b = append(b[:first+recordHeaderSize+9], 0xff)
+			binary.LittleEndian.PutUint32(b[first:], 10)
+			binary.LittleEndian.PutUint32(b[first+4:], crc32.ChecksumIEEE(b[first+recordHeaderSize:]))
+			return b
+		}), 1, first, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []proto.Message
+			good, err := readRecords(bytes.NewReader(tt.b), func(received time.Time, req proto.Message) error {
+				if !received.Equal(time.Unix(1700000000, 5)) {
+					t.Errorf("received %v", received)
+				}
+				got = append(got, req)
+				return nil
+			})
+			if len(got) != tt.records || good != int64(tt.good) || (err != nil) != tt.err {
+				t.Errorf("readRecords() = %d records, offset %d and error %v, want %d, %d and error %v", len(got), good, err, tt.records, tt.good, tt.err)
+			}
+			if len(got) > 0 && !proto.Equal(got[0], testLogsRequest("first")) {
+				t.Errorf("first record %v", got[0])
+			}
+		})
+	}
+}
+
+func TestOpenWALTruncatesCorruptTail(t *testing.T) {
+	setupStorage(Limits{})
+	defer closeFiles()
+	dir := t.TempDir()
+	path := filepath.Join(dir, walFile)
+	valid, first := testRecords(t)
+	if err := os.WriteFile(path, valid[:len(valid)-1], 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := openWAL(dir, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(first) {
+		t.Fatalf("write-ahead file of %d bytes, want it truncated to %d", info.Size(), first)
+	}
+	if len(Storage.logs) != 1 || !Storage.logs[0].Received.Equal(time.Unix(1700000000, 5)) {
+		t.Fatalf("%d logs replayed, want the first one received when it was persisted", len(Storage.logs))
+	}
+
+	// requests are appended after the truncated tail
+	persist(time.Now(), testLogsRequest("second"))
+	closeFiles()
+	setupStorage(Limits{})
+	if err := openWAL(dir, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(Storage.logs) != 2 || Storage.logs[1].Log.Body.GetStringValue() != "second" {
+		t.Errorf("%d logs replayed, want both", len(Storage.logs))
+	}
+}
+
+func TestWALRotation(t *testing.T) {
+	setupStorage(Limits{})
+	defer closeFiles()
+	dir := t.TempDir()
+	if err := openWAL(dir, 1024, 0); err != nil {
+		t.Fatal(err)
+	}
+	for range 100 {
+		persist(time.Now(), testLogsRequest("a log of some length"))
+	}
+	closeFiles()
+
+	for _, name := range []string{walFile, walPrevFile} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || info.Size() > 512+64 {
+			t.Errorf("%s: %v, want at most half of the maximum size", name, err)
+		}
+	}
+	setupStorage(Limits{})
+	if err := openWAL(dir, 1024, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(Storage.logs); n == 0 || n >= 100 {
+		t.Errorf("%d logs replayed, want the ones of both segments only", n)
+	}
+
+	truncateWAL()
+	if _, err := os.Stat(filepath.Join(dir, walPrevFile)); !os.IsNotExist(err) {
+		t.Errorf("previous segment not removed by truncateWAL: %v", err)
+	}
+}
+
+func TestWALRotationByAge(t *testing.T) {
+	setupStorage(Limits{})
+	defer closeFiles()
+	dir := t.TempDir()
+	if err := openWAL(dir, 0, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	persist(start, testLogsRequest("old"))
+	persist(start.Add(2*time.Hour), testLogsRequest("new"))
+	closeFiles()
+
+	for name, body := range map[string]string{walPrevFile: "old", walFile: "new"} {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var bodies []string
+		readRecords(f, func(_ time.Time, req proto.Message) error {
+			bodies = append(bodies, req.(*collogs.ExportLogsServiceRequest).ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body.GetStringValue())
+			return nil
+		})
+		f.Close()
+		if len(bodies) != 1 || bodies[0] != body {
+			t.Errorf("%s has %v, want %s", name, bodies, body)
+		}
+	}
+}
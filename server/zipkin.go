@@ -0,0 +1,310 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	coltraces "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	traces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// TransportZipkin is the Zipkin v2 HTTP API, in JSON or protobuf
+const TransportZipkin = "http/zipkin"
+
+// zipkinNoServiceName is the service.name of spans without a local endpoint, as in the collector
+const zipkinNoServiceName = "OTLPResourceNoServiceName"
+
+// zipkinSpan is a span of the Zipkin v2 model, with IDs hex encoded
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId"`
+	Name           string             `json:"name"`
+	Kind           string             `json:"kind"`
+	Timestamp      uint64             `json:"timestamp"` // microseconds
+	Duration       uint64             `json:"duration"`  // microseconds
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint"`
+	Annotations    []zipkinAnnotation `json:"annotations"`
+	Tags           map[string]string  `json:"tags"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp uint64 `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// handleZipkin receives spans POSTed to the Zipkin v2 API, /api/v2/spans
+func handleZipkin(w http.ResponseWriter, req *http.Request) {
+	handleHTTP(w, req, TransportZipkin, http.StatusAccepted, "X-Rejected-Spans", func(body []byte) (proto.Message, error) {
+		var spans []zipkinSpan
+		var err error
+		switch req.Header.Get("Content-Type") {
+		case "application/x-protobuf", "application/protobuf":
+			spans, err = decodeZipkinProto(body)
+		default:
+			err = json.Unmarshal(body, &spans)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid Zipkin spans: %w", err)
+		}
+		return zipkinToOTLP(spans), nil
+	})
+}
+
+// zipkinToOTLP translates Zipkin spans the way the collector's Zipkin receiver does:
+// the local endpoint becomes the resource, tags become attributes and annotations become events
+func zipkinToOTLP(spans []zipkinSpan) *coltraces.ExportTraceServiceRequest {
+	req := &coltraces.ExportTraceServiceRequest{}
+	byService := map[string]*traces.ResourceSpans{}
+	byScope := map[*traces.ResourceSpans]map[[2]string]*traces.ScopeSpans{}
+
+	for _, zs := range spans {
+		service := zipkinNoServiceName
+		if zs.LocalEndpoint != nil && zs.LocalEndpoint.ServiceName != "" {
+			service = zs.LocalEndpoint.ServiceName
+		}
+		rs, ok := byService[service]
+		if !ok {
+			rs = &traces.ResourceSpans{Resource: &resource.Resource{Attributes: []*v1.KeyValue{stringAttr("service.name", service)}}}
+			byService[service] = rs
+			byScope[rs] = map[[2]string]*traces.ScopeSpans{}
+			req.ResourceSpans = append(req.ResourceSpans, rs)
+		}
+
+		span, scope := zipkinSpanToOTLP(zs)
+		ss, ok := byScope[rs][scope]
+		if !ok {
+			ss = &traces.ScopeSpans{Scope: &v1.InstrumentationScope{Name: scope[0], Version: scope[1]}}
+			byScope[rs][scope] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+		ss.Spans = append(ss.Spans, span)
+	}
+	return req
+}
+
+// zipkinSpanToOTLP translates a span, also returning the name and version of its instrumentation scope
+func zipkinSpanToOTLP(zs zipkinSpan) (*traces.Span, [2]string) {
+	span := &traces.Span{
+		TraceId:           hexID(zs.TraceID, 16),
+		SpanId:            hexID(zs.ID, 8),
+		ParentSpanId:      hexID(zs.ParentID, 8),
+		Name:              zs.Name,
+		StartTimeUnixNano: zs.Timestamp * 1000,
+		EndTimeUnixNano:   (zs.Timestamp + zs.Duration) * 1000,
+		Status:            &traces.Status{},
+	}
+
+	tags := zs.Tags
+	switch strings.ToUpper(zs.Kind) {
+	case "CLIENT":
+		span.Kind = traces.Span_SPAN_KIND_CLIENT
+	case "SERVER":
+		span.Kind = traces.Span_SPAN_KIND_SERVER
+	case "PRODUCER":
+		span.Kind = traces.Span_SPAN_KIND_PRODUCER
+	case "CONSUMER":
+		span.Kind = traces.Span_SPAN_KIND_CONSUMER
+	default:
+		if tags["span.kind"] == "internal" {
+			span.Kind = traces.Span_SPAN_KIND_INTERNAL
+		}
+	}
+
+	var scope [2]string
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := tags[k]
+		switch k {
+		case "otel.status_code":
+			switch strings.ToUpper(v) {
+			case "OK":
+				span.Status.Code = traces.Status_STATUS_CODE_OK
+			case "ERROR":
+				span.Status.Code = traces.Status_STATUS_CODE_ERROR
+			}
+		case "otel.status_description":
+			span.Status.Message = v
+		case "error":
+			span.Status.Code = traces.Status_STATUS_CODE_ERROR
+			if span.Status.Message == "" && v != "true" && v != "" {
+				span.Status.Message = v
+			}
+		case "otel.scope.name", "otel.library.name":
+			scope[0] = v
+		case "otel.scope.version", "otel.library.version":
+			scope[1] = v
+		case "span.kind":
+			if span.Kind == traces.Span_SPAN_KIND_UNSPECIFIED {
+				span.Attributes = append(span.Attributes, stringAttr(k, v))
+			}
+		default:
+			span.Attributes = append(span.Attributes, stringAttr(k, v))
+		}
+	}
+
+	if e := zs.LocalEndpoint; e != nil {
+		span.Attributes = appendEndpoint(span.Attributes, e, "net.host.ip", "net.host.port")
+	}
+	if e := zs.RemoteEndpoint; e != nil {
+		if e.ServiceName != "" {
+			span.Attributes = append(span.Attributes, stringAttr("peer.service", e.ServiceName))
+		}
+		span.Attributes = appendEndpoint(span.Attributes, e, "net.peer.ip", "net.peer.port")
+	}
+
+	for _, a := range zs.Annotations {
+		span.Events = append(span.Events, &traces.Span_Event{TimeUnixNano: a.Timestamp * 1000, Name: a.Value})
+	}
+	return span, scope
+}
+
+func appendEndpoint(attrs []*v1.KeyValue, e *zipkinEndpoint, ipKey, portKey string) []*v1.KeyValue {
+	if e.IPv4 != "" {
+		attrs = append(attrs, stringAttr(ipKey, e.IPv4))
+	} else if e.IPv6 != "" {
+		attrs = append(attrs, stringAttr(ipKey, e.IPv6))
+	}
+	if e.Port != 0 {
+		attrs = append(attrs, &v1.KeyValue{Key: portKey, Value: &v1.AnyValue{Value: &v1.AnyValue_IntValue{IntValue: int64(e.Port)}}})
+	}
+	return attrs
+}
+
+func stringAttr(k, v string) *v1.KeyValue {
+	return &v1.KeyValue{Key: k, Value: &v1.AnyValue{Value: &v1.AnyValue_StringValue{StringValue: v}}}
+}
+
+// hexID decodes a hex ID, left padding it with zeroes to size bytes, eg. 64-bit trace IDs to 128 bits.
+// Invalid IDs are returned as is, to be rejected by validation.
+func hexID(s string, size int) []byte {
+	if s == "" {
+		return nil
+	}
+	b, err := hex.DecodeString(fmt.Sprintf("%0*s", size*2, s))
+	if err != nil {
+		return []byte(s)
+	}
+	return b
+}
+
+// decodeZipkinProto decodes a ListOfSpans message of zipkin.proto
+func decodeZipkinProto(b []byte) ([]zipkinSpan, error) {
+	var spans []zipkinSpan
+	err := walkProto(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		s, err := decodeZipkinSpan(v)
+		spans = append(spans, s)
+		return err
+	})
+	return spans, err
+}
+
+func decodeZipkinSpan(b []byte) (s zipkinSpan, err error) {
+	err = walkProto(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch num {
+		case 1:
+			s.TraceID = hex.EncodeToString(v)
+		case 2:
+			s.ParentID = hex.EncodeToString(v)
+		case 3:
+			s.ID = hex.EncodeToString(v)
+		case 4:
+			s.Kind = map[uint64]string{1: "CLIENT", 2: "SERVER", 3: "PRODUCER", 4: "CONSUMER"}[n]
+		case 5:
+			s.Name = string(v)
+		case 6:
+			s.Timestamp = n
+		case 7:
+			s.Duration = n
+		case 8, 9:
+			e, err := decodeZipkinEndpoint(v)
+			if err != nil {
+				return err
+			}
+			if num == 8 {
+				s.LocalEndpoint = e
+			} else {
+				s.RemoteEndpoint = e
+			}
+		case 10:
+			var a zipkinAnnotation
+			if err := walkProto(v, func(num protowire.Number, _ protowire.Type, v []byte, n uint64) error {
+				switch num {
+				case 1:
+					a.Timestamp = n
+				case 2:
+					a.Value = string(v)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			s.Annotations = append(s.Annotations, a)
+		case 11:
+			var key, value string
+			if err := walkProto(v, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+				switch num {
+				case 1:
+					key = string(v)
+				case 2:
+					value = string(v)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if s.Tags == nil {
+				s.Tags = map[string]string{}
+			}
+			s.Tags[key] = value
+		}
+		return nil
+	})
+	return s, err
+}
+
+func decodeZipkinEndpoint(b []byte) (*zipkinEndpoint, error) {
+	e := &zipkinEndpoint{}
+	err := walkProto(b, func(num protowire.Number, _ protowire.Type, v []byte, n uint64) error {
+		switch num {
+		case 1:
+			e.ServiceName = string(v)
+		case 2:
+			if len(v) > 0 {
+				e.IPv4 = net.IP(v).String()
+			}
+		case 3:
+			if len(v) > 0 {
+				e.IPv6 = net.IP(v).String()
+			}
+		case 4:
+			e.Port = int(int32(n))
+		}
+		return nil
+	})
+	return e, err
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	traces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// attrStrings returns the string and integer attributes of kvs
+func attrStrings(kvs []*v1.KeyValue) map[string]string {
+	attrs := map[string]string{}
+	for _, kv := range kvs {
+		switch v := kv.Value.Value.(type) {
+		case *v1.AnyValue_StringValue:
+			attrs[kv.Key] = v.StringValue
+		case *v1.AnyValue_IntValue:
+			attrs[kv.Key] = strconv.FormatInt(v.IntValue, 10)
+		}
+	}
+	return attrs
+}
+
+// protoBytes appends a length-delimited field
+func protoBytes(b []byte, num protowire.Number, v []byte) []byte {
+	return protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), v)
+}
+
+// protoVarint appends a varint field
+func protoVarint(b []byte, num protowire.Number, v uint64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(b, num, protowire.VarintType), v)
+}
+
+// protoFixed64 appends a 64-bit field
+func protoFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	return protowire.AppendFixed64(protowire.AppendTag(b, num, protowire.Fixed64Type), v)
+}
+
+const testZipkinJSON = `[{
+	"traceId": "5af7183fb1d4cf5f",
+	"id": "6b221d5bc9e6496c",
+	"parentId": "352bff9a74ca9ad2",
+	"name": "get /api",
+	"kind": "SERVER",
+	"timestamp": 1556604172355737,
+	"duration": 1431,
+	"localEndpoint": {"serviceName": "backend", "ipv4": "192.168.99.1", "port": 3306},
+	"remoteEndpoint": {"serviceName": "frontend", "ipv6": "::1"},
+	"annotations": [{"timestamp": 1556604172355800, "value": "wr"}],
+	"tags": {"http.method": "GET", "error": "timeout", "otel.scope.name": "net/http", "otel.scope.version": "1.2"}
+}]`
+
+func TestZipkinSpanToOTLP(t *testing.T) {
+	tests := []struct {
+		name  string
+		span  string
+		check func(*testing.T, *traces.Span, [2]string)
+	}{
+		{
+			name: "IDs",
+			span: `{"traceId": "5af7183fb1d4cf5f", "id": "6b221d5bc9e6496c", "parentId": "352bff9a74ca9ad2"}`,
+			check: func(t *testing.T, s *traces.Span, _ [2]string) {
+				if got := hex.EncodeToString(s.TraceId); got != "00000000000000005af7183fb1d4cf5f" {
+					t.Errorf("trace ID %s, want it padded to 128 bits", got)
+				}
+				if hex.EncodeToString(s.SpanId) != "6b221d5bc9e6496c" || hex.EncodeToString(s.ParentSpanId) != "352bff9a74ca9ad2" {
+					t.Errorf("span ID %x and parent %x", s.SpanId, s.ParentSpanId)
+				}
+			},
+		},
+		{
+			name: "root without parent",
+			span: `{"traceId": "463ac35c9f6413ad48485a3953bb6124", "id": "a2fb4a1d1a96d312"}`,
+			check: func(t *testing.T, s *traces.Span, _ [2]string) {
+				if len(s.TraceId) != 16 || s.ParentSpanId != nil {
+					t.Errorf("trace ID %x and parent %x", s.TraceId, s.ParentSpanId)
+				}
+			},
+		},
+		{
+			name: "times",
+			span: `{"timestamp": 1556604172355737, "duration": 1431}`,
+			check: func(t *testing.T, s *traces.Span, _ [2]string) {
+				if s.StartTimeUnixNano != 1556604172355737000 || s.EndTimeUnixNano != 1556604172357168000 {
+					t.Errorf("start %d and end %d", s.StartTimeUnixNano, s.EndTimeUnixNano)
+				}
+			},
+		},
+		{
+			name: "kind",
+			span: `{"kind": "consumer"}`,
+			check: func(t *testing.T, s *traces.Span, _ [2]string) {
+				if s.Kind != traces.Span_SPAN_KIND_CONSUMER {
+					t.Errorf("kind %v, want consumer", s.Kind)
+				}
+			},
+		},
+		{
+			name: "internal kind tag",
+			span: `{"tags": {"span.kind": "internal"}}`,
+			check: func(t *testing.T, s *traces.Span, _ [2]string) {
+				if s.Kind != traces.Span_SPAN_KIND_INTERNAL {
+					t.Errorf("kind %v, want internal", s.Kind)
+				}
+			},
+		},
+		{
+			name: "otel status",
+			span: `{"tags": {"otel.status_code": "ERROR", "otel.status_description": "boom"}}`,
+			check: func(t *testing.T, s *traces.Span, _ [2]string) {
+				if s.Status.Code != traces.Status_STATUS_CODE_ERROR || s.Status.Message != "boom" || len(s.Attributes) != 0 {
+					t.Errorf("status %v and attributes %v", s.Status, s.Attributes)
+				}
+			},
+		},
+		{
+			name: "error tag",
+			span: `{"tags": {"error": "timeout"}}`,
+			check: func(t *testing.T, s *traces.Span, _ [2]string) {
+				if s.Status.Code != traces.Status_STATUS_CODE_ERROR || s.Status.Message != "timeout" {
+					t.Errorf("status %v, want error timeout", s.Status)
+				}
+			},
+		},
+		{
+			name: "scope and attributes",
+			span: `{"tags": {"otel.library.name": "net/http", "otel.library.version": "1.2", "http.method": "GET"}}`,
+			check: func(t *testing.T, s *traces.Span, scope [2]string) {
+				if scope != [2]string{"net/http", "1.2"} {
+					t.Errorf("scope %v, want net/http 1.2", scope)
+				}
+				if attrs := attrStrings(s.Attributes); len(attrs) != 1 || attrs["http.method"] != "GET" {
+					t.Errorf("attributes %v, want only http.method", attrs)
+				}
+			},
+		},
+		{
+			name: "endpoints",
+			span: `{"localEndpoint": {"serviceName": "backend", "ipv4": "192.168.99.1", "port": 3306},
+				"remoteEndpoint": {"serviceName": "frontend", "ipv6": "::1"}}`,
+			check: func(t *testing.T, s *traces.Span, _ [2]string) {
+				want := map[string]string{"net.host.ip": "192.168.99.1", "net.host.port": "3306", "peer.service": "frontend", "net.peer.ip": "::1"}
+				if attrs := attrStrings(s.Attributes); !reflect.DeepEqual(attrs, want) {
+					t.Errorf("attributes %v, want %v", attrs, want)
+				}
+			},
+		},
+		{
+			name: "annotations",
+			span: `{"annotations": [{"timestamp": 1556604172355800, "value": "wr"}]}`,
+			check: func(t *testing.T, s *traces.Span, _ [2]string) {
+				if len(s.Events) != 1 || s.Events[0].Name != "wr" || s.Events[0].TimeUnixNano != 1556604172355800000 {
+					t.Errorf("events %v, want wr", s.Events)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var zs zipkinSpan
+			if err := json.Unmarshal([]byte(tt.span), &zs); err != nil {
+				t.Fatal(err)
+			}
+			span, scope := zipkinSpanToOTLP(zs)
+			tt.check(t, span, scope)
+		})
+	}
+}
+
+func TestZipkinToOTLP(t *testing.T) {
+	spans := []zipkinSpan{
+		{Name: "a", LocalEndpoint: &zipkinEndpoint{ServiceName: "api"}, Tags: map[string]string{"otel.scope.name": "x"}},
+		{Name: "b"},
+		{Name: "c", LocalEndpoint: &zipkinEndpoint{ServiceName: "api"}, Tags: map[string]string{"otel.scope.name": "x"}},
+		{Name: "d", LocalEndpoint: &zipkinEndpoint{ServiceName: "api"}},
+	}
+	req := zipkinToOTLP(spans)
+	if len(req.ResourceSpans) != 2 {
+		t.Fatalf("%d resources, want api and one without a service", len(req.ResourceSpans))
+	}
+	api, none := req.ResourceSpans[0], req.ResourceSpans[1]
+	if attrs := attrStrings(none.Resource.Attributes); attrs["service.name"] != zipkinNoServiceName {
+		t.Errorf("service.name %q of spans without a local endpoint", attrs["service.name"])
+	}
+	if len(api.ScopeSpans) != 2 || len(api.ScopeSpans[0].Spans) != 2 || api.ScopeSpans[0].Scope.Name != "x" {
+		t.Errorf("scopes of api %v, want a and c in x, and d", api.ScopeSpans)
+	}
+}
+
+func TestDecodeZipkinProto(t *testing.T) {
+	var want []zipkinSpan
+	if err := json.Unmarshal([]byte(testZipkinJSON), &want); err != nil {
+		t.Fatal(err)
+	}
+	id := func(s string) []byte { b, _ := hex.DecodeString(s); return b }
+
+	var local, remote, annotation, span []byte
+	local = protoBytes(local, 1, []byte("backend"))
+	local = protoBytes(local, 2, net.ParseIP("192.168.99.1").To4())
+	local = protoVarint(local, 4, 3306)
+	remote = protoBytes(remote, 1, []byte("frontend"))
+	remote = protoBytes(remote, 3, net.ParseIP("::1"))
+	annotation = protoFixed64(annotation, 1, 1556604172355800)
+	annotation = protoBytes(annotation, 2, []byte("wr"))
+
+	span = protoBytes(span, 1, id("5af7183fb1d4cf5f"))
+	span = protoBytes(span, 2, id("352bff9a74ca9ad2"))
+	span = protoBytes(span, 3, id("6b221d5bc9e6496c"))
+	span = protoVarint(span, 4, 2) // SERVER
+	span = protoBytes(span, 5, []byte("get /api"))
+	span = protoFixed64(span, 6, 1556604172355737)
+	span = protoVarint(span, 7, 1431)
+	span = protoBytes(span, 8, local)
+	span = protoBytes(span, 9, remote)
+	span = protoBytes(span, 10, annotation)
+	for _, k := range []string{"error", "http.method", "otel.scope.name", "otel.scope.version"} {
+		tag := protoBytes(protoBytes(nil, 1, []byte(k)), 2, []byte(want[0].Tags[k]))
+		span = protoBytes(span, 11, tag)
+	}
+	span = protoVarint(span, 14, 1) // unknown field
+	list := protoBytes(nil, 1, span)
+
+	got, err := decodeZipkinProto(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeZipkinProto() = %+v, want %+v", got, want)
+	}
+
+	if _, err := decodeZipkinProto(list[:len(list)-3]); err == nil {
+		t.Error("decodeZipkinProto() of a truncated message succeeded")
+	}
+}
+
+func TestHandleZipkin(t *testing.T) {
+	setupStorage(Limits{})
+	tests := []struct {
+		method string
+		body   string
+		status int
+	}{
+		{http.MethodPost, testZipkinJSON, http.StatusAccepted},
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, `[{"traceId": 1}]`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handleZipkin(rec, httptest.NewRequest(tt.method, "/api/v2/spans", strings.NewReader(tt.body)))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.body, rec.Code, tt.status)
+		}
+	}
+	if n := len(Storage.traces); n != 1 {
+		t.Errorf("%d traces received, want 1", n)
+	}
+}
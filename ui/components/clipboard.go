@@ -0,0 +1,41 @@
+package components
+
+import (
+	"os"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ClipboardMsg is an OSC 52 escape sequence for the root model to write with its next render,
+// as writing to the terminal from a command would race with the renderer
+type ClipboardMsg string
+
+// Copy copies value to the clipboard with an OSC 52 escape sequence, which is handled by
+// the terminal itself and so also works over SSH. what describes the value in the status message.
+func Copy(what, value string) tea.Cmd {
+	seq := osc52.New(value)
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = seq.Tmux()
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		seq = seq.Screen()
+	}
+	return tea.Batch(
+		func() tea.Msg { return ClipboardMsg(seq.String()) },
+		func() tea.Msg { return StatusMsg("copied " + what + " " + preview(value)) },
+	)
+}
+
+// preview shortens value to a single line that fits in the status message
+func preview(value string) string {
+	value, _, cut := strings.Cut(value, "\n")
+	if r := []rune(value); len(r) > 32 {
+		value, cut = string(r[:32]), true
+	}
+	if cut {
+		value += "…"
+	}
+	return value
+}
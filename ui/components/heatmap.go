@@ -0,0 +1,164 @@
+package components
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"pitr.ca/otelui/server"
+)
+
+// heatmapShades are ordered from empty to the highest count
+var heatmapShades = []string{" ", "░", "▒", "▓", "█"}
+
+// Heatmap renders the buckets of a histogram series over time,
+// with time on the X axis and bucket boundaries on the Y axis
+type Heatmap struct {
+	isFocused bool
+	title     string
+
+	w, h int
+
+	name string
+}
+
+func NewHeatmap(title string) *Heatmap {
+	return &Heatmap{title: title}
+}
+
+func (m Heatmap) Help() []key.Binding     { return []key.Binding{} }
+func (m Heatmap) Init() tea.Cmd           { return nil }
+func (m *Heatmap) SetContent(name string) { m.name = name }
+func (m Heatmap) IsFocused() bool         { return m.isFocused }
+func (m *Heatmap) SetFocus(b bool)        { m.isFocused = b }
+
+func (m *Heatmap) Update(msg tea.Msg) tea.Cmd {
+	if msg, ok := msg.(tea.WindowSizeMsg); ok {
+		m.w = msg.Width
+		m.h = msg.Height
+	}
+	return nil
+}
+
+func (m *Heatmap) View() string {
+	empty := lipgloss.NewStyle().Width(m.w).Height(m.h).Render("")
+	dps := server.GetDatapoints(m.name)
+	if dps == nil || dps.Histograms == nil || m.h < 2 {
+		return empty
+	}
+
+	hists := dps.HistogramIntervals()
+
+	// rows are the intervals between all bucket boundaries seen in the series
+	var edges []float64
+	for _, h := range hists {
+		for _, b := range h.Buckets {
+			if b.Count > 0 {
+				edges = append(edges, b.Lower, b.Upper)
+			}
+		}
+	}
+	slices.Sort(edges)
+	edges = slices.Compact(edges)
+	if len(edges) < 2 {
+		return empty
+	}
+	rows := len(edges) - 1
+
+	labels := make([]string, rows)
+	labelW := 0
+	for i := range rows {
+		labels[i] = formatBound(edges[i+1])
+		labelW = max(labelW, lipgloss.Width(labels[i]))
+	}
+
+	plotW := max(1, m.w-labelW-1)
+	if len(hists) > plotW {
+		hists = hists[len(hists)-plotW:]
+	}
+	times := dps.Times[len(dps.Times)-len(hists):]
+
+	// a bucket's count is spread evenly over the rows it covers
+	grid := make([][]float64, len(hists))
+	for x, h := range hists {
+		grid[x] = make([]float64, rows)
+		for _, b := range h.Buckets {
+			if b.Count == 0 {
+				continue
+			}
+			lo, _ := slices.BinarySearch(edges, b.Lower)
+			hi, _ := slices.BinarySearch(edges, b.Upper)
+			for y := lo; y < hi; y++ {
+				grid[x][y] += float64(b.Count) / float64(hi-lo)
+			}
+		}
+	}
+
+	// merge adjacent rows when there are more than fit on screen
+	plotH := m.h - 1
+	groups := min(rows, plotH)
+	groupOf := func(y int) int { return y * groups / rows }
+	cells := make([][]float64, len(grid))
+	maxCount := 0.0
+	for x := range grid {
+		cells[x] = make([]float64, groups)
+		for y, c := range grid[x] {
+			cells[x][groupOf(y)] += c
+		}
+		maxCount = max(maxCount, slices.Max(cells[x]))
+	}
+	groupLabels := make([]string, groups)
+	for y := range rows {
+		groupLabels[groupOf(y)] = labels[y]
+	}
+
+	style := lipgloss.NewStyle().Foreground(AccentColor)
+	var buf strings.Builder
+	for g := groups - 1; g >= 0; g-- {
+		buf.WriteString(lipgloss.PlaceHorizontal(labelW, lipgloss.Right, groupLabels[g]))
+		buf.WriteByte(' ')
+		var row strings.Builder
+		for x := range cells {
+			shade := 0
+			if c := cells[x][g]; c > 0 && maxCount > 0 {
+				shade = 1 + min(len(heatmapShades)-2, int(c/maxCount*float64(len(heatmapShades)-1)))
+			}
+			row.WriteString(heatmapShades[shade])
+		}
+		buf.WriteString(style.Render(row.String()))
+		buf.WriteByte('\n')
+	}
+	for range plotH - groups {
+		buf.WriteByte('\n')
+	}
+
+	first, last := formatTime(times[0]), formatTime(times[len(times)-1])
+	axis := strings.Repeat(" ", labelW+1) + first
+	if len(times) > 1 {
+		axis += strings.Repeat(" ", max(1, len(hists)-lipgloss.Width(first)-lipgloss.Width(last))) + last
+	}
+	buf.WriteString(axis)
+
+	return lipgloss.NewStyle().Width(m.w).MaxWidth(m.w).Height(m.h).MaxHeight(m.h).Render(buf.String())
+}
+
+func formatBound(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%.4g", v)
+}
+
+func formatTime(nsec uint64) string {
+	t := time.Unix(0, int64(nsec))
+	if TZUTC {
+		return t.UTC().Format("15:04:05")
+	}
+	return t.Local().Format("15:04:05")
+}
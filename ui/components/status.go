@@ -0,0 +1,4 @@
+package components
+
+// StatusMsg is a short message shown at the bottom of the screen for a few seconds
+type StatusMsg string
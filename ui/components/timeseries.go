@@ -1,6 +1,8 @@
 package components
 
 import (
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/NimbleMarkets/ntcharts/linechart/timeserieslinechart"
@@ -13,15 +15,38 @@ import (
 
 var TZUTC bool
 
+// histogramStats are the values that can be charted for histogram series
+var histogramStats = []struct {
+	name string
+	fn   func(h *server.Histogram) float64
+}{
+	{"count", func(h *server.Histogram) float64 { return float64(h.Count) }},
+	{"sum", func(h *server.Histogram) float64 { return h.Sum }},
+	{"avg", func(h *server.Histogram) float64 { return h.Avg() }},
+	{"p50", func(h *server.Histogram) float64 { return h.Quantile(0.5) }},
+	{"p90", func(h *server.Histogram) float64 { return h.Quantile(0.9) }},
+	{"p99", func(h *server.Histogram) float64 { return h.Quantile(0.99) }},
+}
+
+type keysTimeseries struct {
+	Stat    key.Binding
+	Heatmap key.Binding
+}
+
 type Timeseries struct {
 	isFocused bool
 	title     string
 
-	model timeserieslinechart.Model
+	model   timeserieslinechart.Model
+	heatmap *Heatmap
 
 	w, h int
 
-	name string
+	keyMap      keysTimeseries
+	name        string
+	histogram   bool
+	stat        int
+	showHeatmap bool
 }
 
 func NewTimeseries(title string) *Timeseries {
@@ -30,13 +55,32 @@ func NewTimeseries(title string) *Timeseries {
 		model: timeserieslinechart.New(0, 0,
 			timeserieslinechart.WithUpdateHandler(timeserieslinechart.SecondNoZoomUpdateHandler(1)),
 		),
+		heatmap: NewHeatmap(title),
+		keyMap: keysTimeseries{
+			Stat:    key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "histogram stat")),
+			Heatmap: key.NewBinding(key.WithKeys("h"), key.WithHelp("h", "heatmap")),
+		},
 	}
 }
 
-func (t Timeseries) Help() []key.Binding     { return []key.Binding{} }
-func (t Timeseries) Init() tea.Cmd           { return nil }
-func (t *Timeseries) SetContent(name string) { t.name = name }
-func (t Timeseries) IsFocused() bool         { return t.isFocused }
+func (t Timeseries) Help() []key.Binding {
+	switch {
+	case t.histogram && t.showHeatmap:
+		return []key.Binding{t.keyMap.Heatmap}
+	case t.histogram:
+		return []key.Binding{t.keyMap.Stat, t.keyMap.Heatmap}
+	}
+	return []key.Binding{}
+}
+
+func (t Timeseries) Init() tea.Cmd { return nil }
+
+func (t *Timeseries) SetContent(name string) {
+	t.name = name
+	t.heatmap.SetContent(name)
+}
+
+func (t Timeseries) IsFocused() bool { return t.isFocused }
 
 func (t *Timeseries) SetFocus(b bool) {
 	t.isFocused = b
@@ -54,6 +98,16 @@ func (t *Timeseries) Update(msg tea.Msg) (cmd tea.Cmd) {
 		t.h = msg.Height
 		t.model.Resize(t.w, t.h)
 		t.model.Focus()
+		t.heatmap.Update(tea.WindowSizeMsg{Width: t.w, Height: max(0, t.h-1)})
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, t.keyMap.Stat) && t.histogram && !t.showHeatmap:
+			t.stat = (t.stat + 1) % len(histogramStats)
+		case key.Matches(msg, t.keyMap.Heatmap) && t.histogram:
+			t.showHeatmap = !t.showHeatmap
+		default:
+			t.model, cmd = t.model.Update(msg)
+		}
 	default:
 		t.model, cmd = t.model.Update(msg)
 	}
@@ -79,12 +133,35 @@ func (t *Timeseries) View() string {
 	t.model.SetViewXYRange(float64(time.Now().Unix()), float64(time.Now().Unix()), 0, 1)
 	t.model.SetYRange(0, 1)
 	dps := server.GetDatapoints(t.name)
+	t.histogram = dps != nil && dps.Histograms != nil
 	if dps == nil {
 		return lipgloss.NewStyle().Width(t.w).Height(t.h).Render("")
 	}
-	for i, ts := range dps.Times {
-		t.model.Push(timeserieslinechart.TimePoint{Time: time.Unix(0, int64(ts)), Value: dps.Values[i]})
+	if !t.histogram {
+		t.model.Resize(t.w, t.h)
+		for i, ts := range dps.Times {
+			t.model.Push(timeserieslinechart.TimePoint{Time: time.Unix(0, int64(ts)), Value: dps.Values[i]})
+		}
+		t.model.DrawAll()
+		return t.model.View()
+	}
+
+	style := lipgloss.NewStyle().Foreground(AccentColor)
+	if t.showHeatmap {
+		header := fmt.Sprintf("heatmap (%d datapoints)", len(dps.Times))
+		return lipgloss.JoinVertical(lipgloss.Left, style.Render(header), t.heatmap.View())
+	}
+
+	// histograms are charted with one stat at a time, cumulative ones per interval
+	stat := histogramStats[t.stat]
+	t.model.Resize(t.w, max(0, t.h-1))
+	for i, h := range dps.HistogramIntervals() {
+		ts := dps.Times[i]
+		if v := stat.fn(h); !math.IsNaN(v) {
+			t.model.Push(timeserieslinechart.TimePoint{Time: time.Unix(0, int64(ts)), Value: v})
+		}
 	}
 	t.model.DrawAll()
-	return t.model.View()
+	header := fmt.Sprintf("%s (%d datapoints)", stat.name, len(dps.Times))
+	return lipgloss.JoinVertical(lipgloss.Left, style.Render(header), t.model.View())
 }
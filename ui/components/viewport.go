@@ -30,6 +30,7 @@ type keysViewport struct {
 	Right  key.Binding
 	Search key.Binding
 	Esc    key.Binding
+	Yank   key.Binding
 }
 
 type Viewport struct {
@@ -37,6 +38,8 @@ type Viewport struct {
 	title     string
 
 	onSelect func(ViewRow)
+	onYank   func(ViewRow) (what, value string)
+	onFilter func(query string) (func(ViewRow) bool, error)
 
 	w, h      int
 	_border   lipgloss.Border
@@ -55,12 +58,14 @@ type Viewport struct {
 	searching    bool
 	searchInput  textinput.Model
 	searchFilter string
+	searchMatch  func(ViewRow) bool // nil if not filtering
+	searchErr    error              // of the query being typed, the previous valid one still filters
 }
 
 func NewViewport(title string) *Viewport {
 	ti := textinput.New()
 	ti.Prompt = "/"
-	ti.CharLimit = 128
+	ti.CharLimit = 256
 	return &Viewport{
 		title:    title,
 		selected: -1,
@@ -75,6 +80,7 @@ func NewViewport(title string) *Viewport {
 			Right:  key.NewBinding(key.WithKeys("right")),
 			Search: key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "search")),
 			Esc:    key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "cancel search")),
+			Yank:   key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "copy")),
 		},
 		searchInput: ti,
 		_border:     lipgloss.RoundedBorder(),
@@ -90,11 +96,23 @@ func (v *Viewport) WithSelectFunc(f func(ViewRow)) *Viewport {
 	return v
 }
 
+// WithYankFunc sets what is copied to the clipboard for a row, which is its plain text by default
+func (v *Viewport) WithYankFunc(f func(ViewRow) (what, value string)) *Viewport {
+	v.onYank = f
+	return v
+}
+
+// WithFilterFunc replaces the substring search with a query, compiled to a predicate over rows
+func (v *Viewport) WithFilterFunc(f func(query string) (func(ViewRow) bool, error)) *Viewport {
+	v.onFilter = f
+	return v
+}
+
 func (v Viewport) Help() []key.Binding {
 	if v.searching {
 		return []key.Binding{v.keyMap.Esc}
 	}
-	return []key.Binding{v.keyMap.Search}
+	return []key.Binding{v.keyMap.Search, v.keyMap.Yank}
 }
 
 func (v *Viewport) SetFocus(b bool)       { v.isFocused = b }
@@ -130,20 +148,22 @@ func (v *Viewport) Update(msg tea.Msg) tea.Cmd {
 			wasFiltered := v.searchFilter != ""
 			v.searching = false
 			v.searchInput.Blur()
-			v.searchFilter = ""
+			v.setFilter("")
 			v.lines = v.allLines
 			v.longestLineWidth = v.findLongestLineWidth(v.lines)
 			if wasFiltered {
 				v.scrollTo(0)
 			}
+		case key.Matches(msg, v.keyMap.Yank) && !v.searching:
+			cmd = v.yank()
 		case key.Matches(msg, v.keyMap.Search) && !v.searching:
 			v.searching = true
 			v.searchInput.SetValue("")
-			v.searchFilter = ""
+			v.setFilter("")
 			cmd = v.searchInput.Focus()
 		case v.searching:
 			v.searchInput, cmd = v.searchInput.Update(msg)
-			v.searchFilter = strings.ToLower(v.searchInput.Value())
+			v.setFilter(v.searchInput.Value())
 			v.lines = v.filterLines(v.allLines)
 			v.longestLineWidth = v.findLongestLineWidth(v.lines)
 			v.scrollTo(0)
@@ -171,6 +191,9 @@ func (v *Viewport) View() string {
 	var top string
 	if v.searching {
 		top = fg.Render(v._border.TopLeft+v._border.Top) + v.searchInput.View()
+		if v.searchErr != nil {
+			top += " " + ansi.Truncate(lipgloss.NewStyle().Foreground(ErrorColor).Render(v.searchErr.Error()), max(0, v.w-lipgloss.Width(top)), "…")
+		}
 	} else {
 		top = fg.Render(v._border.TopLeft+v._border.Top, v.title+" ")
 	}
@@ -204,10 +227,45 @@ func (v *Viewport) SetContent(lines []ViewRow) {
 	}
 }
 
+// Rows returns the rows left after filtering by the current search
+func (v *Viewport) Rows() []ViewRow { return v.lines }
+
+// SelectedRow returns the selected row, if there is one
+func (v *Viewport) SelectedRow() (ViewRow, bool) {
+	if v.selected < 0 || v.selected >= len(v.lines) {
+		return ViewRow{}, false
+	}
+	return v.lines[v.selected], true
+}
+
+// Text returns the rows left after filtering as plain text
+func (v *Viewport) Text() string {
+	lines := make([]string, len(v.lines))
+	for i, l := range v.lines {
+		lines[i] = ansi.Strip(l.Str)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (v *Viewport) yank() tea.Cmd {
+	row, ok := v.SelectedRow()
+	if !ok {
+		return nil
+	}
+	what, value := "row", strings.TrimSpace(ansi.Strip(row.Str))
+	if v.onYank != nil {
+		what, value = v.onYank(row)
+	}
+	if value == "" {
+		return nil
+	}
+	return Copy(what, value)
+}
+
 func (v *Viewport) SetSearch(filter string) tea.Cmd {
 	v.searching = true
 	v.searchInput.SetValue(filter)
-	v.searchFilter = filter
+	v.setFilter(filter)
 	v.lines = v.filterLines(v.allLines)
 	v.longestLineWidth = v.findLongestLineWidth(v.lines)
 	v.scrollTo(0)
@@ -225,14 +283,40 @@ func (v *Viewport) AddContent(lines []ViewRow) {
 	v.scrollTo(v.selected)
 }
 
+// setFilter sets the search, which is a query if there is a filter func, or else a substring of rows
+func (v *Viewport) setFilter(filter string) {
+	v.searchFilter, v.searchErr = filter, nil
+	switch {
+	case filter == "":
+		v.searchMatch = nil
+	case v.onFilter != nil:
+		match, err := v.onFilter(filter)
+		if err != nil {
+			v.searchErr = err
+			return
+		}
+		v.searchMatch = match
+	default:
+		v.searchMatch = SubstringMatch(filter)
+	}
+}
+
+// SubstringMatch matches rows whose text or Search contains filter, ignoring case
+func SubstringMatch(filter string) func(ViewRow) bool {
+	lower := strings.ToLower(filter)
+	return func(l ViewRow) bool {
+		return strings.Contains(strings.ToLower(ansi.Strip(l.Str)), lower) ||
+			(l.Search != "" && strings.Contains(strings.ToLower(l.Search), lower))
+	}
+}
+
 func (v *Viewport) filterLines(all []ViewRow) []ViewRow {
-	if v.searchFilter == "" {
+	if v.searchMatch == nil {
 		return all
 	}
 	var out []ViewRow
 	for _, l := range all {
-		if strings.Contains(strings.ToLower(ansi.Strip(l.Str)), v.searchFilter) ||
-			(l.Search != "" && strings.Contains(strings.ToLower(l.Search), v.searchFilter)) {
+		if v.searchMatch(l) {
 			out = append(out, l)
 		}
 	}
@@ -0,0 +1,152 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltraces "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	v1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	traces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"pitr.ca/otelui/server"
+	"pitr.ca/otelui/ui/components"
+)
+
+// exportFile writes an export to a new file in the working directory, named after what is exported
+func exportFile(kind, ext, what string, content func() ([]byte, error)) tea.Cmd {
+	return func() tea.Msg {
+		b, err := content()
+		if err != nil {
+			return components.StatusMsg(fmt.Sprintf("failed to export %s: %s", what, err))
+		}
+		name := fmt.Sprintf("otelui-%s-%s.%s", kind, time.Now().Format("20060102-150405"), ext)
+		if err := os.WriteFile(name, b, 0o644); err != nil {
+			return components.StatusMsg(fmt.Sprintf("failed to export %s: %s", what, err))
+		}
+		return components.StatusMsg(fmt.Sprintf("exported %s to %s", what, name))
+	}
+}
+
+// exportText exports the content of a details pane
+func exportText(kind string, v *components.Viewport) tea.Cmd {
+	text := v.Text()
+	return exportFile(kind, "txt", "details", func() ([]byte, error) { return []byte(text), nil })
+}
+
+// exportOTLP exports requests as newline-delimited OTLP/JSON, which can be imported again
+func exportOTLP(kind, what string, reqs ...proto.Message) tea.Cmd {
+	return exportFile(kind, "jsonl", what, func() ([]byte, error) {
+		var buf bytes.Buffer
+		for _, req := range reqs {
+			b, err := server.MarshalJSON(req)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(b)
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), nil
+	})
+}
+
+// exportCSV exports datapoints of metric series, one row per datapoint
+func exportCSV(kind string, names []string) tea.Cmd {
+	what := fmt.Sprintf("%d series", len(names))
+	if len(names) == 1 {
+		what = names[0]
+	}
+	return exportFile(kind, "csv", what, func() ([]byte, error) {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"series", "time_unix_nano", "time", "value"})
+		for _, name := range names {
+			dps := server.GetDatapoints(name)
+			if dps == nil {
+				continue
+			}
+			for i, ts := range dps.Times {
+				w.Write([]string{
+					name,
+					strconv.FormatUint(ts, 10),
+					time.Unix(0, int64(ts)).UTC().Format(time.RFC3339Nano),
+					strconv.FormatFloat(dps.Values[i], 'g', -1, 64),
+				})
+			}
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	})
+}
+
+// logsRequest groups logs back into an export request by their resource and scope
+func logsRequest(ls []*server.Log) *collogs.ExportLogsServiceRequest {
+	req := &collogs.ExportLogsServiceRequest{}
+	rls := map[*logs.ResourceLogs]*logs.ResourceLogs{}
+	sls := map[*logs.ScopeLogs]*logs.ScopeLogs{}
+	for _, l := range ls {
+		rl, ok := rls[l.ResourceLogs]
+		if !ok {
+			rl = &logs.ResourceLogs{Resource: l.ResourceLogs.Resource, SchemaUrl: l.ResourceLogs.SchemaUrl}
+			rls[l.ResourceLogs] = rl
+			req.ResourceLogs = append(req.ResourceLogs, rl)
+		}
+		sl, ok := sls[l.ScopeLogs]
+		if !ok {
+			sl = &logs.ScopeLogs{Scope: l.ScopeLogs.Scope, SchemaUrl: l.ScopeLogs.SchemaUrl}
+			sls[l.ScopeLogs] = sl
+			rl.ScopeLogs = append(rl.ScopeLogs, sl)
+		}
+		sl.LogRecords = append(sl.LogRecords, l.Log)
+	}
+	return req
+}
+
+// tracesRequest groups spans of traces back into an export request by their resource and scope
+func tracesRequest(ts ...*server.Trace) *coltraces.ExportTraceServiceRequest {
+	req := &coltraces.ExportTraceServiceRequest{}
+	rss := map[*resource.Resource]*traces.ResourceSpans{}
+	sss := map[*resource.Resource]map[*v1.InstrumentationScope]*traces.ScopeSpans{}
+	for _, t := range ts {
+		for _, s := range t.Spans {
+			rs, ok := rss[s.Resource]
+			if !ok {
+				rs = &traces.ResourceSpans{Resource: s.Resource}
+				rss[s.Resource] = rs
+				sss[s.Resource] = map[*v1.InstrumentationScope]*traces.ScopeSpans{}
+				req.ResourceSpans = append(req.ResourceSpans, rs)
+			}
+			ss, ok := sss[s.Resource][s.Scope]
+			if !ok {
+				ss = &traces.ScopeSpans{Scope: s.Scope}
+				sss[s.Resource][s.Scope] = ss
+				rs.ScopeSpans = append(rs.ScopeSpans, ss)
+			}
+			ss.Spans = append(ss.Spans, s.Span)
+		}
+	}
+	return req
+}
+
+// payloadRequest wraps a payload back into the export request it was received in
+func payloadRequest(p *server.Payload) proto.Message {
+	switch pp := p.Payload.(type) {
+	case []*logs.ResourceLogs:
+		return &collogs.ExportLogsServiceRequest{ResourceLogs: pp}
+	case []*traces.ResourceSpans:
+		return &coltraces.ExportTraceServiceRequest{ResourceSpans: pp}
+	case []*metrics.ResourceMetrics:
+		return &colmetrics.ExportMetricsServiceRequest{ResourceMetrics: pp}
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/charmbracelet/lipgloss/tree"
 	logs "go.opentelemetry.io/proto/otlp/logs/v1"
 
+	"pitr.ca/otelui/query"
 	"pitr.ca/otelui/server"
 	"pitr.ca/otelui/ui/components"
 	"pitr.ca/otelui/utils"
@@ -19,6 +20,7 @@ import (
 
 type keyMapLogs struct {
 	GoToTraces key.Binding
+	Export     key.Binding
 }
 
 type logsModel struct {
@@ -32,11 +34,12 @@ func newLogsModel(title string) tea.Model {
 	m := &logsModel{
 		keyMap: keyMapLogs{
 			GoToTraces: key.NewBinding(key.WithKeys("T"), key.WithHelp("T", "jump to trace")),
+			Export:     key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "export")),
 		},
 	}
 	m.view = components.NewSplitview(
-		components.NewViewport(title).WithSelectFunc(m.updateDetailsContent),
-		components.NewViewport("Details"),
+		components.NewViewport(title).WithSelectFunc(m.updateDetailsContent).WithYankFunc(yankLog).WithFilterFunc(filterLogs),
+		components.NewViewport("Details").WithYankFunc(yankDetail),
 	)
 	return m
 }
@@ -48,7 +51,11 @@ func (m logsModel) IsCapturingInput() bool { return m.view.IsCapturingInput() }
 
 func (m logsModel) Help() []key.Binding {
 	bindings := m.view.Help()
-	if !m.IsCapturingInput() && m.selected != nil && len(m.selected.Log.TraceId) > 0 {
+	if m.IsCapturingInput() {
+		return bindings
+	}
+	bindings = append(bindings, m.keyMap.Export)
+	if m.selected != nil && len(m.selected.Log.TraceId) > 0 {
 		bindings = append(bindings, m.keyMap.GoToTraces)
 	}
 	return bindings
@@ -77,6 +84,9 @@ func (m *logsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, func() tea.Msg { return navigateMsg{mRootTraces, filter} }
 			}
 		}
+		if key.Matches(msg, m.keyMap.Export) && !m.IsCapturingInput() {
+			return m, m.export()
+		}
 		m.view, cmd = m.view.Update(msg)
 		return m, cmd
 	default:
@@ -117,7 +127,7 @@ func (m *logsModel) updateMainContent() {
 		buf.WriteString(renderForeground(col, lipgloss.PlaceHorizontal(3, lipgloss.Left, l.Log.SeverityText)))
 		buf.WriteByte(' ')
 		buf.WriteString(utils.AnyToString(l.Log.Body))
-		search := attrsSearch(l.Log.Attributes, l.ScopeLogs.Scope.Attributes, l.ResourceLogs.Resource.Attributes)
+		search := attrsSearch(l.Log.Attributes, l.ScopeLogs.GetScope().GetAttributes(), l.ResourceLogs.GetResource().GetAttributes())
 
 		lines = append(lines, components.ViewRow{Str: buf.String(), Raw: l, Search: search})
 		buf.Reset()
@@ -143,10 +153,10 @@ func (m *logsModel) updateDetailsContent(selected components.ViewRow) {
 	if attrs, set := attrsToTree("Attributes", selectedLog.Log.Attributes); set {
 		t.Child(attrs)
 	}
-	if sattrs, set := attrsToTree("Attributes", selectedLog.ScopeLogs.Scope.Attributes); set {
-		t.Child(tree.Root(fmt.Sprintf("Scope for %s (%s)", selectedLog.ScopeLogs.Scope.Name, selectedLog.ScopeLogs.Scope.Version)).Child(sattrs))
+	if sattrs, set := attrsToTree("Attributes", selectedLog.ScopeLogs.GetScope().GetAttributes()); set {
+		t.Child(tree.Root(fmt.Sprintf("Scope for %s (%s)", selectedLog.ScopeLogs.GetScope().GetName(), selectedLog.ScopeLogs.GetScope().GetVersion())).Child(sattrs))
 	}
-	if rattrs, set := attrsToTree("Resource Attributes", selectedLog.ResourceLogs.Resource.Attributes); set {
+	if rattrs, set := attrsToTree("Resource Attributes", selectedLog.ResourceLogs.GetResource().GetAttributes()); set {
 		t.Child(rattrs)
 	}
 	if selectedLog.Log.EventName != "" {
@@ -164,3 +174,37 @@ func (m *logsModel) updateDetailsContent(selected components.ViewRow) {
 	}
 	m.view.Bot().SetContent(lines)
 }
+
+// filterLogs compiles a log query, see query.ParseLogs
+func filterLogs(q string) (func(components.ViewRow) bool, error) {
+	f, err := query.ParseLogs(q)
+	if err != nil {
+		return nil, err
+	}
+	return func(row components.ViewRow) bool {
+		l, ok := row.Raw.(*server.Log)
+		return ok && f(l)
+	}, nil
+}
+
+func yankLog(row components.ViewRow) (string, string) {
+	l, _ := row.Raw.(*server.Log)
+	if l == nil {
+		return "", ""
+	}
+	return "log body", utils.AnyToString(l.Log.Body)
+}
+
+// export writes the filtered logs, or the details of the selected log when focused
+func (m *logsModel) export() tea.Cmd {
+	if m.view.Bot().IsFocused() {
+		return exportText("log", m.view.Bot())
+	}
+	var ls []*server.Log
+	for _, row := range m.view.Top().Rows() {
+		if l, ok := row.Raw.(*server.Log); ok {
+			ls = append(ls, l)
+		}
+	}
+	return exportOTLP("logs", fmt.Sprintf("%d logs", len(ls)), logsRequest(ls))
+}
@@ -10,15 +10,25 @@ import (
 	"pitr.ca/otelui/ui/components"
 )
 
+type keyMapMetrics struct {
+	Export key.Binding
+}
+
 type metricsModel struct {
 	view        components.Splitview[*components.Viewport, *components.Timeseries]
 	lastMetrics int
+	keyMap      keyMapMetrics
 }
 
 func newMetricsModel(title string) tea.Model {
-	m := metricsModel{lastMetrics: -1}
+	m := metricsModel{
+		lastMetrics: -1,
+		keyMap: keyMapMetrics{
+			Export: key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "export")),
+		},
+	}
 	m.view = components.NewSplitview(
-		components.NewViewport(title).WithSelectFunc(m.updateDetailsContent),
+		components.NewViewport(title).WithSelectFunc(m.updateDetailsContent).WithYankFunc(yankMetric),
 		components.NewTimeseries("Details"),
 	)
 	return m
@@ -26,9 +36,15 @@ func newMetricsModel(title string) tea.Model {
 
 func (m metricsModel) Init() tea.Cmd          { return nil }
 func (m metricsModel) View() string           { return m.view.View() }
-func (m metricsModel) Help() []key.Binding    { return m.view.Help() }
 func (m metricsModel) IsCapturingInput() bool { return m.view.IsCapturingInput() }
 
+func (m metricsModel) Help() []key.Binding {
+	if m.IsCapturingInput() {
+		return m.view.Help()
+	}
+	return append(m.view.Help(), m.keyMap.Export)
+}
+
 func (m metricsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
@@ -43,6 +59,12 @@ func (m metricsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.lastMetrics = msg.Metrics
 			m.updateMainContent()
 		}
+	case tea.KeyMsg:
+		if key.Matches(msg, m.keyMap.Export) && !m.IsCapturingInput() {
+			return m, m.export()
+		}
+		m.view, cmd = m.view.Update(msg)
+		return m, cmd
 	default:
 		m.view, cmd = m.view.Update(msg)
 		return m, cmd
@@ -64,3 +86,23 @@ func (m *metricsModel) updateDetailsContent(selected components.ViewRow) {
 	metric, _ := selected.Raw.(string)
 	m.view.Bot().SetContent(metric)
 }
+
+func yankMetric(row components.ViewRow) (string, string) {
+	name, _ := row.Raw.(string)
+	return "metric", name
+}
+
+// export writes datapoints of the filtered series, or of the selected series when the chart is focused
+func (m *metricsModel) export() tea.Cmd {
+	var names []string
+	if m.view.Bot().IsFocused() {
+		if row, ok := m.view.Top().SelectedRow(); ok {
+			names = append(names, row.Raw.(string))
+		}
+	} else {
+		for _, row := range m.view.Top().Rows() {
+			names = append(names, row.Raw.(string))
+		}
+	}
+	return exportCSV("metrics", names)
+}
@@ -11,30 +11,46 @@ import (
 	logs "go.opentelemetry.io/proto/otlp/logs/v1"
 	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
 	traces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
 
 	"pitr.ca/otelui/server"
 	"pitr.ca/otelui/ui/components"
 	"pitr.ca/otelui/utils"
 )
 
+type keyMapPayloads struct {
+	Export key.Binding
+}
+
 type payloadsModel struct {
-	view components.Splitview[*components.Viewport, *components.Viewport]
+	view   components.Splitview[*components.Viewport, *components.Viewport]
+	keyMap keyMapPayloads
 
 	lastPayloads int
 }
 
 func newPayloadsModel(title string) tea.Model {
-	m := payloadsModel{}
+	m := payloadsModel{
+		keyMap: keyMapPayloads{
+			Export: key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "export")),
+		},
+	}
 	m.view = components.NewSplitview(
-		components.NewViewport(title).WithSelectFunc(m.updateDetailsContent),
-		components.NewViewport("Details"),
+		components.NewViewport(title).WithSelectFunc(m.updateDetailsContent).WithYankFunc(yankPayload),
+		components.NewViewport("Details").WithYankFunc(yankDetail),
 	)
 	return m
 }
 
-func (m payloadsModel) Init() tea.Cmd       { return nil }
-func (m payloadsModel) Help() []key.Binding { return m.view.Help() }
-func (m payloadsModel) View() string        { return m.view.View() }
+func (m payloadsModel) Init() tea.Cmd { return nil }
+func (m payloadsModel) View() string  { return m.view.View() }
+
+func (m payloadsModel) Help() []key.Binding {
+	if m.IsCapturingInput() {
+		return m.view.Help()
+	}
+	return append(m.view.Help(), m.keyMap.Export)
+}
 
 func (m payloadsModel) IsCapturingInput() bool { return m.view.IsCapturingInput() }
 
@@ -52,6 +68,11 @@ func (m payloadsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.lastPayloads = msg.Payloads
 			m.updateMainContent()
 		}
+	case tea.KeyMsg:
+		if key.Matches(msg, m.keyMap.Export) && !m.IsCapturingInput() {
+			return m, m.export()
+		}
+		m.view, cmd = m.view.Update(msg)
 	default:
 		m.view, cmd = m.view.Update(msg)
 	}
@@ -67,9 +88,9 @@ func (m *payloadsModel) updateMainContent() {
 		case []*logs.ResourceLogs:
 			t = "logs"
 			for _, rl := range pp {
-				search.WriteString(attrsSearch(rl.Resource.Attributes))
+				search.WriteString(attrsSearch(rl.GetResource().GetAttributes()))
 				for _, sl := range rl.ScopeLogs {
-					search.WriteString(attrsSearch(sl.Scope.Attributes))
+					search.WriteString(attrsSearch(sl.GetScope().GetAttributes()))
 					for _, lr := range sl.LogRecords {
 						search.WriteString(utils.AnyToString(lr.Body))
 						search.WriteByte(' ')
@@ -80,9 +101,9 @@ func (m *payloadsModel) updateMainContent() {
 		case []*traces.ResourceSpans:
 			t = "spans"
 			for _, rs := range pp {
-				search.WriteString(attrsSearch(rs.Resource.Attributes))
+				search.WriteString(attrsSearch(rs.GetResource().GetAttributes()))
 				for _, ss := range rs.ScopeSpans {
-					search.WriteString(attrsSearch(ss.Scope.Attributes))
+					search.WriteString(attrsSearch(ss.GetScope().GetAttributes()))
 					for _, s := range ss.Spans {
 						search.WriteString(s.Name)
 						search.WriteByte(' ')
@@ -93,9 +114,9 @@ func (m *payloadsModel) updateMainContent() {
 		case []*metrics.ResourceMetrics:
 			t = "metrics"
 			for _, rm := range pp {
-				search.WriteString(attrsSearch(rm.Resource.Attributes))
+				search.WriteString(attrsSearch(rm.GetResource().GetAttributes()))
 				for _, sm := range rm.ScopeMetrics {
-					search.WriteString(attrsSearch(sm.Scope.Attributes))
+					search.WriteString(attrsSearch(sm.GetScope().GetAttributes()))
 					for _, metric := range sm.Metrics {
 						search.WriteString(metric.Name)
 						search.WriteByte(' ')
@@ -109,6 +130,32 @@ func (m *payloadsModel) updateMainContent() {
 	m.view.Top().SetContent(payloads)
 }
 
+func yankPayload(row components.ViewRow) (string, string) {
+	p, _ := row.Raw.(*server.Payload)
+	if p == nil {
+		return "", ""
+	}
+	b, err := server.MarshalJSON(payloadRequest(p))
+	if err != nil {
+		return "", ""
+	}
+	return "payload JSON", string(b)
+}
+
+// export writes the filtered payloads, or the details of the selected payload when focused
+func (m *payloadsModel) export() tea.Cmd {
+	if m.view.Bot().IsFocused() {
+		return exportText("payload", m.view.Bot())
+	}
+	var reqs []proto.Message
+	for _, row := range m.view.Top().Rows() {
+		if p, ok := row.Raw.(*server.Payload); ok {
+			reqs = append(reqs, payloadRequest(p))
+		}
+	}
+	return exportOTLP("payloads", fmt.Sprintf("%d payloads", len(reqs)), reqs...)
+}
+
 func (m *payloadsModel) updateDetailsContent(selected components.ViewRow) {
 	row, _ := selected.Raw.(*server.Payload)
 	if row == nil {
@@ -143,7 +190,7 @@ func (m *payloadsModel) updateDetailsContent(selected components.ViewRow) {
 					Child(scopeToTree(sl.Scope)).
 					Child(t3))
 			}
-			rattrs, _ := attrsToTree("Attributes", rl.Resource.Attributes)
+			rattrs, _ := attrsToTree("Attributes", rl.GetResource().GetAttributes())
 			t = t.Child(tree.Root("ResourceLog").
 				Child("Schema URL: " + rl.SchemaUrl).
 				Child(rattrs).
@@ -204,7 +251,7 @@ func (m *payloadsModel) updateDetailsContent(selected components.ViewRow) {
 					Child(scopeToTree(ss.Scope)).
 					Child(t3))
 			}
-			rattrs, _ := attrsToTree("Attributes", rs.Resource.Attributes)
+			rattrs, _ := attrsToTree("Attributes", rs.GetResource().GetAttributes())
 			t = t.Child(tree.Root("ResourceSpan").
 				Child("Schema URL: " + rs.SchemaUrl).
 				Child(rattrs).
@@ -217,7 +264,7 @@ func (m *payloadsModel) updateDetailsContent(selected components.ViewRow) {
 		t := tree.Root(fmt.Sprintf("ResourceMetrics (%d)", len(p)))
 		for _, rm := range p {
 			refs := tree.Root("EntityRefs")
-			for _, r := range rm.Resource.EntityRefs {
+			for _, r := range rm.GetResource().GetEntityRefs() {
 				refs = refs.Child(tree.Root("EntityRef").
 					Child("SchemaUrl: " + r.SchemaUrl).
 					Child("Type: " + r.Type).
@@ -313,7 +360,7 @@ func (m *payloadsModel) updateDetailsContent(selected components.ViewRow) {
 					Child(scopeToTree(sm.Scope)).
 					Child(t3))
 			}
-			rattrs, _ := attrsToTree("Attributes", rm.Resource.Attributes)
+			rattrs, _ := attrsToTree("Attributes", rm.GetResource().GetAttributes())
 			t = t.Child(tree.Root("ResourceMetric").
 				Child("Schema URL: " + rm.SchemaUrl).
 				Child(rattrs).
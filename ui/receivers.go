@@ -0,0 +1,209 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/tree"
+
+	"pitr.ca/otelui/server"
+	"pitr.ca/otelui/ui/components"
+)
+
+// receiverRow is a transport or peer, with its rates since the previous refresh
+type receiverRow struct {
+	stats   server.ReceiverStats
+	peer    *server.PeerStats
+	forward *server.ForwardStats
+	name    string
+
+	requests, records, bytes float64 // per second
+}
+
+type receiversModel struct {
+	view components.Splitview[*components.Viewport, *components.Viewport]
+
+	prev     map[string]server.ReceiverStats
+	prevTime time.Time
+	rates    map[string][3]float64 // requests, records and bytes per second
+}
+
+func newReceiversModel(title string) tea.Model {
+	m := &receiversModel{}
+	m.view = components.NewSplitview(
+		components.NewViewport(title).WithSelectFunc(m.updateDetailsContent),
+		components.NewViewport("Details").WithYankFunc(yankDetail),
+	)
+	return m
+}
+
+func (m receiversModel) Init() tea.Cmd          { return nil }
+func (m receiversModel) View() string           { return m.view.View() }
+func (m receiversModel) Help() []key.Binding    { return m.view.Help() }
+func (m receiversModel) IsCapturingInput() bool { return m.view.IsCapturingInput() }
+
+func (m *receiversModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case refreshMsg:
+		if msg.reset {
+			m.prev, m.rates = nil, nil
+		}
+		m.updateMainContent(false)
+	case server.ConsumeEvent:
+		// rates change even when nothing is received, so this refreshes on every event
+		m.updateMainContent(true)
+	default:
+		m.view, cmd = m.view.Update(msg)
+	}
+	return m, cmd
+}
+
+// updateMainContent lists the stats of transports and peers, rates are only updated on ticks
+// so that they are measured over about a second
+func (m *receiversModel) updateMainContent(tick bool) {
+	now := time.Now()
+	elapsed := now.Sub(m.prevTime).Seconds()
+	transports, peers := server.GetReceiverStats()
+	cur := map[string]server.ReceiverStats{}
+	rates := map[string][3]float64{}
+
+	row := func(key, name string, s server.ReceiverStats, p *server.PeerStats) components.ViewRow {
+		cur[key] = s
+		r := &receiverRow{stats: s, peer: p, name: name}
+		if prev, ok := m.prev[key]; ok && tick && elapsed > 0 {
+			rates[key] = [3]float64{
+				float64(s.Requests-prev.Requests) / elapsed,
+				float64(s.Records-prev.Records) / elapsed,
+				float64(s.DecodedBytes-prev.DecodedBytes) / elapsed,
+			}
+		} else if !tick {
+			rates[key] = m.rates[key]
+		}
+		r.requests, r.records, r.bytes = rates[key][0], rates[key][1], rates[key][2]
+		str := fmt.Sprintf("%-40s %8.1f req/s %10.1f rec/s %10s/s  avg %-8s", name, r.requests, r.records, formatBytes(r.bytes), s.AvgLatency().Round(time.Microsecond))
+		if s.Errors > 0 {
+			str += renderForeground(components.ErrorColor, fmt.Sprintf(" errors %d", s.Errors))
+		}
+		if s.Rejected > 0 {
+			str += renderForeground(components.WarnColor, fmt.Sprintf(" rejected %d", s.Rejected))
+		}
+		search := name
+		if p != nil {
+			search += " " + strings.Join(p.Services, " ") + " " + strings.Join(p.SDKs, " ")
+		}
+		return components.ViewRow{Str: str, Raw: r, Search: search}
+	}
+	header := func(s string) components.ViewRow {
+		return components.ViewRow{Str: lipgloss.NewStyle().Bold(true).Render(s)}
+	}
+
+	names := make([]string, 0, len(transports))
+	for name := range transports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := []components.ViewRow{header("Transports")}
+	for _, name := range names {
+		rows = append(rows, row("transport "+name, name, transports[name], nil))
+	}
+	rows = append(rows, header("Peers"))
+	for i := range peers {
+		p := &peers[i]
+		name := p.Addr
+		if len(p.Services) > 0 {
+			name += " " + strings.Join(p.Services, ",")
+		} else if p.UserAgent != "" {
+			name += " " + p.UserAgent
+		}
+		rows = append(rows, row("peer "+p.Addr+" "+p.UserAgent, name, p.ReceiverStats, p))
+	}
+
+	if f := server.GetForwardStats(); f.Target != "" {
+		rows = append(rows, header("Forwarding"))
+		str := fmt.Sprintf("%-40s %8d sent %10d queued", f.Target, f.Sent, f.Queued)
+		if f.Retries > 0 {
+			str += renderForeground(components.WarnColor, fmt.Sprintf(" retries %d", f.Retries))
+		}
+		if f.Failed+f.Dropped > 0 {
+			str += renderForeground(components.ErrorColor, fmt.Sprintf(" failed %d dropped %d", f.Failed, f.Dropped))
+		}
+		rows = append(rows, components.ViewRow{Str: str, Raw: &receiverRow{forward: &f, name: f.Target}, Search: f.Target})
+	}
+
+	m.rates = rates
+	if tick {
+		m.prev, m.prevTime = cur, now
+	}
+	m.view.Top().SetContent(rows)
+}
+
+func (m *receiversModel) updateDetailsContent(selected components.ViewRow) {
+	r, _ := selected.Raw.(*receiverRow)
+	if r == nil {
+		m.view.Bot().SetContent([]components.ViewRow{})
+		return
+	}
+
+	t := tree.Root(r.name)
+	if f := r.forward; f != nil {
+		lastError := "none"
+		if f.LastError != "" {
+			lastError = nanoToString(uint64(f.LastErrorAt.UnixNano())) + " " + f.LastError
+		}
+		t.Child(fmt.Sprintf("Sent: %d", f.Sent)).
+			Child(fmt.Sprintf("Queued: %d", f.Queued)).
+			Child(fmt.Sprintf("Retries: %d", f.Retries)).
+			Child(fmt.Sprintf("Failed: %d", f.Failed)).
+			Child(fmt.Sprintf("Dropped: %d", f.Dropped)).
+			Child("Last error: " + lastError)
+		m.setDetails(t)
+		return
+	}
+
+	s := r.stats
+	if p := r.peer; p != nil {
+		t.Child("Address: " + p.Addr).
+			Child("User-Agent: " + p.UserAgent).
+			Child("Transports: " + strings.Join(p.Transports, ", ")).
+			Child("Services: " + strings.Join(p.Services, ", ")).
+			Child("SDKs: " + strings.Join(p.SDKs, ", "))
+	}
+	t.Child(fmt.Sprintf("Requests: %d (%.1f/s)", s.Requests, r.requests)).
+		Child(fmt.Sprintf("Records: %d (%.1f/s)", s.Records, r.records)).
+		Child(fmt.Sprintf("Decoded bytes: %s (%s/s)", formatBytes(float64(s.DecodedBytes)), formatBytes(r.bytes))).
+		Child(fmt.Sprintf("Errors: %d", s.Errors)).
+		Child(fmt.Sprintf("Rejected: %d", s.Rejected)).
+		Child("Average latency: " + s.AvgLatency().String()).
+		Child("Last seen: " + nanoToString(uint64(s.LastSeen.UnixNano())))
+	m.setDetails(t)
+}
+
+func (m *receiversModel) setDetails(t *tree.Tree) {
+	lines := []components.ViewRow{}
+	for l := range strings.SplitSeq(t.String(), "\n") {
+		lines = append(lines, components.ViewRow{Str: l})
+	}
+	m.view.Bot().SetContent(lines)
+}
+
+// formatBytes formats a size with a binary unit, eg. 1.5 KiB
+func formatBytes(b float64) string {
+	const units = "KMGTPE"
+	if b < 1024 {
+		return fmt.Sprintf("%.0f B", b)
+	}
+	i := -1
+	for b >= 1024 && i < len(units)-1 {
+		b /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", b, units[i])
+}
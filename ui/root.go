@@ -22,11 +22,21 @@ type navigateMsg struct {
 	filter string
 }
 
+type clearStatusMsg struct{ id int }
+
+type clearClipboardMsg struct{ id int }
+
+const statusDuration = 4 * time.Second
+
+// clipboardDuration is how long an OSC 52 sequence is kept in the view, long enough for a frame to be rendered
+const clipboardDuration = time.Second / 4
+
 const (
 	mRootLogs mRoot = iota
 	mRootTraces
 	mRootMetrics
 	mRootPayloads
+	mRootReceivers
 
 	mRootTopOffset = 1
 )
@@ -43,13 +53,21 @@ type model struct {
 	keyMap keyMapRoot
 	help   help.Model
 
-	mode   mRoot
-	w      int
-	models map[mRoot]tea.Model
+	mode     mRoot
+	w        int
+	models   map[mRoot]tea.Model
+	evicted  int
+	rejected server.Rejected
+	forward  server.ForwardStats
+	status   string
+	statusID int
+
+	clipboard   string
+	clipboardID int
 }
 
 func newRootModel() tea.Model {
-	names := []string{"Logs", "Traces", "Metrics", "Payloads"}
+	names := []string{"Logs", "Traces", "Metrics", "Payloads", "Receivers"}
 	return &model{
 		keyMap: keyMapRoot{
 			Next:  key.NewBinding(key.WithKeys("]"), key.WithHelp("[ ]", "switch mode")),
@@ -60,10 +78,11 @@ func newRootModel() tea.Model {
 		},
 		help: help.New(),
 		models: map[mRoot]tea.Model{
-			mRootLogs:     newLogsModel(rootTabTitle(names, mRootLogs)),
-			mRootTraces:   newTracesModel(rootTabTitle(names, mRootTraces)),
-			mRootMetrics:  newMetricsModel(rootTabTitle(names, mRootMetrics)),
-			mRootPayloads: newPayloadsModel(rootTabTitle(names, mRootPayloads)),
+			mRootLogs:      newLogsModel(rootTabTitle(names, mRootLogs)),
+			mRootTraces:    newTracesModel(rootTabTitle(names, mRootTraces)),
+			mRootMetrics:   newMetricsModel(rootTabTitle(names, mRootMetrics)),
+			mRootPayloads:  newPayloadsModel(rootTabTitle(names, mRootPayloads)),
+			mRootReceivers: newReceiversModel(rootTabTitle(names, mRootReceivers)),
 		},
 	}
 }
@@ -83,9 +102,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case server.ConsumeEvent:
+		evicted := m.evicted != msg.Evicted
+		m.evicted = msg.Evicted
+		m.rejected = msg.Rejected
+		m.forward = msg.Forward
 		for k, v := range m.models {
 			m.models[k], cmd = v.Update(msg)
 			cmds = append(cmds, cmd)
+			// evictions don't change the counts of received items, so they need a refresh
+			if evicted {
+				m.models[k], cmd = m.models[k].Update(refreshMsg{})
+				cmds = append(cmds, cmd)
+			}
 		}
 		cmd = tea.Batch(cmds...)
 	case tea.WindowSizeMsg:
@@ -96,6 +124,26 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, cmd)
 		}
 		cmd = tea.Batch(cmds...)
+	case components.StatusMsg:
+		m.status = string(msg)
+		m.statusID++
+		id := m.statusID
+		return m, tea.Tick(statusDuration, func(time.Time) tea.Msg { return clearStatusMsg{id} })
+	case clearStatusMsg:
+		if msg.id == m.statusID {
+			m.status = ""
+		}
+		return m, nil
+	case components.ClipboardMsg:
+		m.clipboard = string(msg)
+		m.clipboardID++
+		id := m.clipboardID
+		return m, tea.Tick(clipboardDuration, func(time.Time) tea.Msg { return clearClipboardMsg{id} })
+	case clearClipboardMsg:
+		if msg.id == m.clipboardID {
+			m.clipboard = ""
+		}
+		return m, nil
 	case navigateMsg:
 		m.mode = msg.mode
 		m.models[m.mode], cmd = m.models[m.mode].Update(msg)
@@ -111,7 +159,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, m.keyMap.Next) && !capturing:
 			m.mode = (m.mode + 1) % mRoot(len(m.models))
 		case key.Matches(msg, m.keyMap.Prev) && !capturing:
-			m.mode = (m.mode - 1) % mRoot(len(m.models))
+			m.mode = (m.mode + mRoot(len(m.models)) - 1) % mRoot(len(m.models))
 		case key.Matches(msg, m.keyMap.TZ) && !capturing:
 			tzUTC = !tzUTC
 			components.TZUTC = tzUTC
@@ -122,6 +170,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmd = tea.Batch(cmds...)
 		case key.Matches(msg, m.keyMap.Reset):
 			server.Reset()
+			m.evicted = 0
+			m.rejected = server.Rejected{}
+			m.forward = server.ForwardStats{}
 			for k, v := range m.models {
 				m.models[k], cmd = v.Update(refreshMsg{reset: true})
 				cmds = append(cmds, cmd)
@@ -154,8 +205,36 @@ func (m model) View() string {
 	} else {
 		keys = []key.Binding{m.keyMap.Next, m.keyMap.Reset, m.keyMap.TZ}
 	}
-	m.help.Width = m.w
-	return m.models[m.mode].View() + "\n " + m.help.ShortHelpView(keys)
+	status := ""
+	if m.evicted > 0 {
+		status = lipgloss.NewStyle().Foreground(components.DebugColor).Render(fmt.Sprintf(" evicted %d", m.evicted))
+	}
+	if r := rejectedStatus(m.rejected); r != "" {
+		status += lipgloss.NewStyle().Foreground(components.WarnColor).Render(" rejected " + r)
+	}
+	if f := m.forward; f.Failed+f.Dropped > 0 {
+		status += lipgloss.NewStyle().Foreground(components.ErrorColor).Render(fmt.Sprintf(" forward failed %d dropped %d", f.Failed, f.Dropped))
+	}
+	if m.status != "" {
+		status += lipgloss.NewStyle().Foreground(components.AccentColor).Render(" " + m.status)
+	}
+	m.help.Width = m.w - lipgloss.Width(status) - 1
+	// the clipboard sequence takes no space, the renderer writes it along with the first line
+	return m.clipboard + m.models[m.mode].View() + "\n " + m.help.ShortHelpView(keys) + status
+}
+
+// rejectedStatus lists the signals with rejected records, eg. "spans 3 datapoints 1"
+func rejectedStatus(r server.Rejected) string {
+	var parts []string
+	for _, c := range []struct {
+		name  string
+		count int
+	}{{"logs", r.Logs}, {"spans", r.Spans}, {"datapoints", r.Datapoints}} {
+		if c.count > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", c.name, c.count))
+		}
+	}
+	return strings.Join(parts, " ")
 }
 
 func rootTabTitle(names []string, m mRoot) string {
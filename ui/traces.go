@@ -12,6 +12,7 @@ import (
 	"github.com/charmbracelet/lipgloss/tree"
 	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
 
+	"pitr.ca/otelui/query"
 	"pitr.ca/otelui/server"
 	"pitr.ca/otelui/ui/components"
 )
@@ -29,6 +30,7 @@ type keyMapTraces struct {
 	Enter    key.Binding
 	Esc      key.Binding
 	GoToLogs key.Binding
+	Export   key.Binding
 }
 
 type tracesModel struct {
@@ -51,12 +53,13 @@ func newTracesModel(title string) tea.Model {
 			Enter:    key.NewBinding(key.WithKeys("enter")),
 			Esc:      key.NewBinding(key.WithKeys("esc")),
 			GoToLogs: key.NewBinding(key.WithKeys("L"), key.WithHelp("L", "jump to logs")),
+			Export:   key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "export")),
 		},
 	}
 	m.views = [3]*components.Viewport{
-		components.NewViewport(title).WithSelectFunc(m.updateSpanTree),
-		components.NewViewport("Spans").WithSelectFunc(m.updateSpanDetails),
-		components.NewViewport("Details"),
+		components.NewViewport(title).WithSelectFunc(m.updateSpanTree).WithYankFunc(yankTrace).WithFilterFunc(filterTraces),
+		components.NewViewport("Spans").WithSelectFunc(m.updateSpanDetails).WithYankFunc(yankSpan),
+		components.NewViewport("Details").WithYankFunc(yankDetail),
 	}
 	m.views[0].SetFocus(true)
 	return m
@@ -71,6 +74,7 @@ func (m *tracesModel) Help() []key.Binding {
 	}
 	bindings := []key.Binding{m.keyMap.Next, m.keyMap.Increase}
 	bindings = append(bindings, m.views[m.focus].Help()...)
+	bindings = append(bindings, m.keyMap.Export)
 	if m.selected != nil {
 		bindings = append(bindings, m.keyMap.GoToLogs)
 	}
@@ -129,9 +133,11 @@ func (m *tracesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case key.Matches(msg, m.keyMap.GoToLogs) && !capturing:
 			if m.selected != nil {
-				filter := m.selected.TraceID[:6]
+				filter := "trace_id=" + m.selected.TraceID
 				return m, func() tea.Msg { return navigateMsg{mRootLogs, filter} }
 			}
+		case key.Matches(msg, m.keyMap.Export) && !capturing:
+			return m, m.export()
 		default:
 			m.viewAt(m.focus).Update(msg)
 		}
@@ -185,6 +191,21 @@ func (m *tracesModel) updateTraceList() {
 	m.views[0].SetContent(rows)
 }
 
+// filterTraces compiles a trace query, see query.ParseTraces, anything without a spanset is searched for in the list
+func filterTraces(q string) (func(components.ViewRow) bool, error) {
+	if !strings.Contains(q, "{") {
+		return components.SubstringMatch(q), nil
+	}
+	f, err := query.ParseTraces(q)
+	if err != nil {
+		return nil, err
+	}
+	return func(row components.ViewRow) bool {
+		t, ok := row.Raw.(*server.Trace)
+		return ok && f(t)
+	}, nil
+}
+
 func (m *tracesModel) updateSpanTree(selected components.ViewRow) {
 	trace, _ := selected.Raw.(*server.Trace)
 	m.selected = trace
@@ -319,6 +340,48 @@ func (m *tracesModel) updateSpanDetails(selected components.ViewRow) {
 	m.views[2].SetContent(lines)
 }
 
+func yankTrace(row components.ViewRow) (string, string) {
+	t, _ := row.Raw.(*server.Trace)
+	if t == nil {
+		return "", ""
+	}
+	return "trace ID", t.TraceID
+}
+
+func yankSpan(row components.ViewRow) (string, string) {
+	s, _ := row.Raw.(*server.Span)
+	if s == nil {
+		return "", ""
+	}
+	b, err := server.MarshalJSON(s.Span)
+	if err != nil {
+		return "", ""
+	}
+	return "span JSON", string(b)
+}
+
+// export writes the filtered traces, the selected trace or the details of the selected span,
+// depending on the focused pane
+func (m *tracesModel) export() tea.Cmd {
+	switch m.focus {
+	case 0:
+		var ts []*server.Trace
+		for _, row := range m.views[0].Rows() {
+			if t, ok := row.Raw.(*server.Trace); ok {
+				ts = append(ts, t)
+			}
+		}
+		return exportOTLP("traces", fmt.Sprintf("%d traces", len(ts)), tracesRequest(ts...))
+	case 1:
+		if m.selected == nil {
+			return nil
+		}
+		return exportOTLP("trace", "trace "+m.selected.TraceID, tracesRequest(m.selected))
+	default:
+		return exportText("span", m.views[2])
+	}
+}
+
 func ganttBar(startNano, endNano, traceStart, traceEnd uint64, w int) string {
 	if w <= 0 || traceStart == traceEnd {
 		return strings.Repeat(" ", max(0, w))
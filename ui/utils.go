@@ -8,11 +8,13 @@ import (
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/tree"
+	"github.com/charmbracelet/x/ansi"
 	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 	v1 "go.opentelemetry.io/proto/otlp/common/v1"
 	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
 	resource "go.opentelemetry.io/proto/otlp/resource/v1"
 
+	"pitr.ca/otelui/ui/components"
 	"pitr.ca/otelui/utils"
 )
 
@@ -72,12 +74,12 @@ func attrsToTree(name string, kvs []*v1.KeyValue) (t *tree.Tree, set bool) {
 }
 
 func scopeToTree(scope *v1.InstrumentationScope) *tree.Tree {
-	sattrs, _ := attrsToTree("Attributes", scope.Attributes)
+	sattrs, _ := attrsToTree("Attributes", scope.GetAttributes())
 	return tree.Root("Scope").
-		Child("Scope.Name: " + scope.Name).
-		Child("Scope.Version: " + scope.Version).
+		Child("Scope.Name: " + scope.GetName()).
+		Child("Scope.Version: " + scope.GetVersion()).
 		Child(sattrs).
-		Child("DroppedAttributesCount: " + fmt.Sprint(scope.DroppedAttributesCount))
+		Child("DroppedAttributesCount: " + fmt.Sprint(scope.GetDroppedAttributesCount()))
 }
 
 func exemplarsToTree(es []*metrics.Exemplar) *tree.Tree {
@@ -148,3 +150,18 @@ func resourceToServiceName(r *resource.Resource) string {
 func renderForeground(c lipgloss.TerminalColor, str string) string {
 	return strings.ReplaceAll(lipgloss.NewStyle().Foreground(c).Render(str), "\x1b[0m", "\x1b[39m")
 }
+
+// yankDetail copies the value of a line of a details tree, eg. "abc" of "├── key: abc (string)"
+func yankDetail(row components.ViewRow) (string, string) {
+	line := strings.TrimLeft(ansi.Strip(row.Str), "│├└─ ")
+	if _, value, ok := strings.Cut(line, ": "); ok {
+		for _, typ := range []string{"string", "bool", "int", "double", "array", "kvlist", "bytes", "(null)"} {
+			if v, ok := strings.CutSuffix(value, " ("+typ+")"); ok {
+				value = v
+				break
+			}
+		}
+		return "value", value
+	}
+	return "line", line
+}